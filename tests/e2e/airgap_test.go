@@ -29,7 +29,7 @@ import (
 )
 
 var _ = Describe("E2E - Build the airgap archive", Label("prepare-archive"), func() {
-	It("Execute the script to build the archive", func() {
+	It("Execute the script to build the archive", Label("qase-none"), func() {
 		// Force to latest if nothing is defined
 		if certManagerVersion == "" {
 			certManagerVersion = "latest"
@@ -46,7 +46,7 @@ var _ = Describe("E2E - Build the airgap archive", Label("prepare-archive"), fun
 })
 
 var _ = Describe("E2E - Deploy K3S/Rancher in airgap environment", Label("airgap-rancher"), func() {
-	It("Create the rancher-manager machine", func() {
+	It("Create the rancher-manager machine", Label("qase-none"), func() {
 		By("Updating the default network configuration", func() {
 			// Don't check return code, as the default network could be already removed
 			for _, c := range []string{"net-destroy", "net-undefine"} {
@@ -73,7 +73,7 @@ var _ = Describe("E2E - Deploy K3S/Rancher in airgap environment", Label("airgap
 		})
 	})
 
-	It("Install K3S/Rancher in the rancher-manager machine", func() {
+	It("Install K3S/Rancher in the rancher-manager machine", Label("qase-none"), func() {
 		airgapRepo := os.Getenv("HOME") + "/airgap_rancher"
 		archiveFile := "airgap_rancher.zst"
 		certPath := "/quay.io/jetstack/"
@@ -29,10 +29,7 @@ import (
 )
 
 var _ = Describe("E2E - Install a simple application", Label("install-app"), func() {
-	It("Install HelloWorld application", func() {
-		// Report to Qase
-		testCaseID = 31
-
+	It("Install HelloWorld application", Label("qase-31"), func() {
 		kubeConfig, err := rancher.SetClientKubeConfig(clusterNS, clusterName)
 		defer os.Remove(kubeConfig)
 		Expect(err).To(Not(HaveOccurred()))
@@ -139,10 +136,7 @@ var _ = Describe("E2E - Install a simple application", Label("install-app"), fun
 })
 
 var _ = Describe("E2E - Checking a simple application", Label("check-app"), func() {
-	It("Check HelloWorld application", func() {
-		// Report to Qase
-		testCaseID = 63
-
+	It("Check HelloWorld application", Label("qase-63"), func() {
 		appName := "hello-world"
 
 		// File where to host client cluster kubeconfig
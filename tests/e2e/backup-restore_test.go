@@ -15,6 +15,7 @@ limitations under the License.
 package e2e_test
 
 import (
+	"bytes"
 	"os"
 	"os/exec"
 	"strings"
@@ -24,13 +25,215 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/backup"
+	"github.com/rancher/elemental/tests/e2e/helpers/fingerprint"
 )
 
 const (
-	backupResourceName  = "elemental-backup"
-	restoreResourceName = "elemental-restore"
+	backupResourceName          = "elemental-backup"
+	encryptedBackupResourceName = "elemental-backup-encrypted"
+	encryptionKeyName           = "elemental-e2e"
+	restoreResourceName         = "elemental-restore"
 )
 
+// Elemental resources that must be saved by the backup
+var requiredBackupResources = []string{
+	"machineinventories.elemental.cattle.io",
+	"machineinventoryselectortemplates.elemental.cattle.io",
+	"machineregistrations.elemental.cattle.io",
+	"managedosversionchannels.elemental.cattle.io",
+	"seedimages.elemental.cattle.io",
+}
+
+/*
+Get the filename of the backup archive
+  - @param name Name of the backup resource
+  - @returns Name of the backup file
+*/
+func getBackupFile(name string) string {
+	var file string
+
+	Eventually(func() string {
+		file, _ = kubectl.RunWithoutErr("get", "backup", name,
+			"-o", "jsonpath={.status.filename}")
+		return file
+	}, tools.SetTimeout(2*time.Minute), 10*time.Second).Should(Not(BeEmpty()))
+
+	return file
+}
+
+/*
+Count resources in the cluster
+  - @param fullResource Resource name with its group, e.g. machineinventories.elemental.cattle.io
+  - @returns Number of resources in all namespaces
+*/
+func countResources(fullResource string) int {
+	out, err := kubectl.RunWithoutErr("get", fullResource, "--all-namespaces", "-o", "name")
+	Expect(err).To(Not(HaveOccurred()))
+
+	return len(strings.Fields(out))
+}
+
+/*
+Read the backup archive from the configured storage
+  - @param backupFile Name of the backup file
+  - @returns Content of the backup file
+*/
+func readBackupFile(backupFile string) []byte {
+	if backupStorage == "s3" {
+		data, err := GetS3Client().GetObject(s3Bucket, backupFile)
+		Expect(err).To(Not(HaveOccurred()))
+		return data
+	}
+
+	// Backup file is owned by root
+	data, err := exec.Command("sudo", "cat", GetBackupDir()+"/"+backupFile).Output()
+	Expect(err).To(Not(HaveOccurred()))
+	return data
+}
+
+/*
+Check that all Elemental resources are in the backup archive
+  - @param backupFile Name of the backup file
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func checkBackupArchive(backupFile string) {
+	entries, err := backup.Read(bytes.NewReader(readBackupFile(backupFile)))
+	Expect(err).To(Not(HaveOccurred()))
+	Expect(entries).To(Not(BeEmpty()))
+
+	checkArchiveResources(entries)
+
+	// Secrets created by the operator should also be saved
+	types, err := kubectl.RunWithoutErr("get", "secrets", "--all-namespaces",
+		"-o", "jsonpath={.items[*].type}")
+	Expect(err).To(Not(HaveOccurred()))
+
+	secrets := 0
+	for _, t := range strings.Fields(types) {
+		if strings.HasPrefix(t, "elemental.cattle.io/") {
+			secrets++
+		}
+	}
+
+	backupSecrets, err := backup.CountSecrets(entries, "elemental.cattle.io/")
+	Expect(err).To(Not(HaveOccurred()))
+	Expect(backupSecrets).To(Equal(secrets), "Wrong number of Elemental secrets in backup")
+}
+
+/*
+Check that all Elemental resources are in the encrypted backup archive and that secrets are not readable
+  - @param backupFile Name of the backup file
+  - @param keyName Name of the encryption key
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func checkEncryptedArchive(backupFile, keyName string) {
+	entries, err := backup.Read(bytes.NewReader(readBackupFile(backupFile)))
+	Expect(err).To(Not(HaveOccurred()))
+	Expect(entries).To(Not(BeEmpty()))
+
+	checkArchiveResources(entries)
+
+	// All secrets should only contain ciphertext
+	Expect(backup.Count(entries, "secrets")).To(BeNumerically(">", 0))
+	for _, e := range entries {
+		if e.FullResource() == "secrets" {
+			Expect(backup.IsEncrypted(e, keyName)).To(BeTrue(),
+				"Secret "+e.Namespace+"/"+e.Name+" is not encrypted")
+		}
+	}
+
+	// Secrets created by the operator (registration tokens, plans...) should be saved
+	out, err := kubectl.RunWithoutErr("get", "secrets", "--all-namespaces",
+		"-o", "jsonpath={range .items[*]}{.type} {.metadata.name}{\"\\n\"}{end}")
+	Expect(err).To(Not(HaveOccurred()))
+
+	secrets := 0
+	for _, line := range strings.Split(out, "\n") {
+		t, name, found := strings.Cut(line, " ")
+		if found && strings.HasPrefix(t, "elemental.cattle.io/") {
+			Expect(backup.Has(entries, "secrets", name)).To(BeTrue(), "Secret "+name+" not found in backup")
+			secrets++
+		}
+	}
+	Expect(secrets).To(BeNumerically(">", 0))
+}
+
+/*
+Check that Elemental resources are in the backup archive, as many as in the cluster
+  - @param entries Resources of the archive
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func checkArchiveResources(entries []backup.Entry) {
+	// Get all Elemental CRDs
+	out, err := kubectl.RunWithoutErr("get", "crd", "-o", "jsonpath={.items[*].metadata.name}")
+	Expect(err).To(Not(HaveOccurred()))
+
+	var crds []string
+	for _, crd := range strings.Fields(out) {
+		if strings.HasSuffix(crd, ".elemental.cattle.io") {
+			crds = append(crds, crd)
+		}
+	}
+	Expect(crds).To(ContainElements(requiredBackupResources))
+
+	for _, crd := range crds {
+		// The CRD itself should be saved, otherwise the ResourceSet is not complete
+		Expect(backup.Has(entries, "customresourcedefinitions.apiextensions.k8s.io", crd)).To(BeTrue(),
+			"CRD "+crd+" not found in backup")
+
+		count := countResources(crd)
+		GinkgoWriter.Printf("%s: %d resource(s) in cluster\n", crd, count)
+		Expect(backup.Count(entries, crd)).To(Equal(count), "Wrong number of "+crd+" in backup")
+	}
+}
+
+/*
+Create the secret with the EncryptionConfiguration used by rancher-backup
+  - @param name Name of the secret
+  - @param keyName Name of the encryption key
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func createEncryptionSecret(name, keyName string) {
+	config, err := backup.GenerateEncryptionConfig(keyName)
+	Expect(err).To(Not(HaveOccurred()))
+
+	configFile, err := tools.CreateTemp("encryption-config")
+	Expect(err).To(Not(HaveOccurred()))
+	defer os.Remove(configFile)
+	Expect(tools.WriteFile(configFile, config)).To(Succeed())
+
+	_, err = kubectl.RunWithoutErr("create", "secret", "generic", name,
+		"--namespace", "cattle-resources-system",
+		"--from-file="+backup.EncryptionConfigKey+"="+configFile)
+	Expect(err).To(Not(HaveOccurred()))
+}
+
+/*
+Create a restore resource for an encrypted backup
+  - @param name Name of the restore resource
+  - @param backupFile Name of the backup file
+  - @param secret Name of the secret with the EncryptionConfiguration
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func applyEncryptedRestore(name, backupFile, secret string) {
+	restoreFile, err := tools.CreateTemp("restore")
+	Expect(err).To(Not(HaveOccurred()))
+	defer os.Remove(restoreFile)
+	Expect(tools.CopyFile(restoreEncryptedYaml, restoreFile)).To(Succeed())
+
+	patterns := []YamlPattern{
+		{key: "%RESTORE_NAME%", value: name},
+		{key: "%BACKUP_FILE%", value: backupFile},
+		{key: "%ENCRYPTION_SECRET%", value: secret},
+	}
+	for _, p := range patterns {
+		Expect(tools.Sed(p.key, p.value, restoreFile)).To(Succeed())
+	}
+
+	Expect(kubectl.Apply(clusterNS, restoreFile)).To(Succeed())
+}
+
 var _ = Describe("E2E - Install Backup/Restore Operator", Label("install-backup-restore"), func() {
 	// Create kubectl context
 	// Default timeout is too small, so New() cannot be used
@@ -40,10 +243,7 @@ var _ = Describe("E2E - Install Backup/Restore Operator", Label("install-backup-
 		PollInterval: 500 * time.Millisecond,
 	}
 
-	It("Install Backup/Restore Operator", func() {
-		// Report to Qase
-		testCaseID = 64
-
+	It("Install Backup/Restore Operator", Label("qase-64"), func() {
 		By("Installing rancher-backup-operator", func() {
 			InstallBackupOperator(k)
 		})
@@ -61,10 +261,12 @@ var _ = Describe("E2E - Test full Backup/Restore", Label("test-full-backup-resto
 
 	var backupFile string
 
-	It("Do a full backup/restore test", func() {
-		// TODO: use another case id for full backup/restore test
-		// Report to Qase
-		// testCaseID = 65
+	// TODO: add a Qase case for the full backup/restore test
+	It("Do a full backup/restore test", Label("qase-none"), func() {
+		var fingerprintBefore fingerprint.Fingerprint
+		By("Capturing fingerprint of Elemental resources", func() {
+			fingerprintBefore = CaptureFingerprint("before-full-backup")
+		})
 
 		By("Adding a backup resource", func() {
 			err := kubectl.Apply(clusterNS, backupYaml)
@@ -81,10 +283,11 @@ var _ = Describe("E2E - Test full Backup/Restore", Label("test-full-backup-resto
 			CheckBackupRestore("Done with backup")
 		})
 
-		By("Copying the backup file", func() {
-			// Get local storage path
-			localPath := GetBackupDir()
+		By("Checking backup archive content", func() {
+			checkBackupArchive(getBackupFile(backupResourceName))
+		})
 
+		By("Copying the backup file", func() {
 			// Get the backup file from the previous backup
 			file, err := kubectl.RunWithoutErr("get", "backup", backupResourceName, "-o", "jsonpath={.status.filename}")
 			Expect(err).To(Not(HaveOccurred()))
@@ -92,56 +295,43 @@ var _ = Describe("E2E - Test full Backup/Restore", Label("test-full-backup-resto
 			// Share the filename across other functions
 			backupFile = file
 
-			// Copy backup file
-			err = exec.Command("sudo", "cp", localPath+"/"+backupFile, ".").Run()
-			Expect(err).To(Not(HaveOccurred()))
-		})
-
-		By("Uninstalling K8s", func() {
-			if strings.Contains(k8sUpstreamVersion, "rke2") {
-				out, err := exec.Command("sudo", "/usr/local/bin/rke2-uninstall.sh").CombinedOutput()
-				Expect(err).To(Not(HaveOccurred()), out)
+			if backupStorage == "s3" {
+				// Download backup file through the S3 API
+				Expect(os.WriteFile(backupFile, readBackupFile(backupFile), 0644)).To(Succeed())
 			} else {
-				out, err := exec.Command("k3s-uninstall.sh").CombinedOutput()
-				Expect(err).To(Not(HaveOccurred()), out)
+				// Copy backup file from local storage path
+				err = exec.Command("sudo", "cp", GetBackupDir()+"/"+backupFile, ".").Run()
+				Expect(err).To(Not(HaveOccurred()))
 			}
 		})
 
-		if strings.Contains(k8sUpstreamVersion, "rke2") {
-			By("Installing RKE2", func() {
-				InstallRKE2()
-			})
-
-			// Use the new Kube config
-			err := os.Setenv("KUBECONFIG", "/etc/rancher/rke2/rke2.yaml")
+		By("Uninstalling K8s", func() {
+			err := upstreamCluster.Uninstall()
 			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Starting RKE2", func() {
-				StartRKE2()
-			})
-
-			By("Waiting for RKE2 to be started", func() {
-				WaitForRKE2(k)
-			})
+		By("Installing "+upstreamCluster.Name(), func() {
+			err := upstreamCluster.Install()
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Installing local-path-provisionner", func() {
-				InstallLocalStorage(k)
-			})
-		} else {
-			By("Installing K3s", func() {
-				InstallK3s()
-			})
+		// Use the new Kube config
+		err := os.Setenv("KUBECONFIG", upstreamCluster.Kubeconfig())
+		Expect(err).To(Not(HaveOccurred()))
 
-			// Use the new Kube config
-			err := os.Setenv("KUBECONFIG", "/etc/rancher/k3s/k3s.yaml")
+		By("Starting "+upstreamCluster.Name(), func() {
+			err := upstreamCluster.Start()
 			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Starting K3s", func() {
-				StartK3s()
-			})
+		By("Waiting for "+upstreamCluster.Name()+" to be started", func() {
+			err := upstreamCluster.Wait(k)
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Waiting for K3s to be started", func() {
-				WaitForK3s(k)
+		if upstreamCluster.NeedsLocalStorage() {
+			By("Installing local-path-provisionner", func() {
+				InstallLocalStorage(k)
 			})
 		}
 
@@ -150,12 +340,21 @@ var _ = Describe("E2E - Test full Backup/Restore", Label("test-full-backup-resto
 		})
 
 		By("Copying backup file to restore", func() {
-			// Get new local storage path
-			localPath := GetBackupDir()
+			if backupStorage == "s3" {
+				// Upload backup file through the S3 API
+				data, err := os.ReadFile(backupFile)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(GetS3Client().PutObject(s3Bucket, backupFile, data)).To(Succeed())
 
-			// Copy backup file
-			err := exec.Command("sudo", "cp", backupFile, localPath).Run()
-			Expect(err).To(Not(HaveOccurred()))
+				// Make sure the operator can see it
+				objects, err := GetS3Client().ListObjects(s3Bucket, backupFile)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(objects).To(ContainElement(backupFile))
+			} else {
+				// Copy backup file to new local storage path
+				err := exec.Command("sudo", "cp", backupFile, GetBackupDir()).Run()
+				Expect(err).To(Not(HaveOccurred()))
+			}
 		})
 
 		By("Adding a restore resource", func() {
@@ -200,13 +399,21 @@ var _ = Describe("E2E - Test full Backup/Restore", Label("test-full-backup-resto
 		By("Checking cluster state after restore", func() {
 			WaitCluster(clusterNS, clusterName)
 		})
+
+		By("Checking that Elemental resources have been restored without drift", func() {
+			CheckFingerprint(fingerprintBefore)
+		})
 	})
 })
 
 var _ = Describe("E2E - Test simple Backup/Restore", Label("test-simple-backup-restore"), func() {
-	It("Do a backup", func() {
-		// Report to Qase
-		testCaseID = 65
+	// Shared between backup and restore
+	var fingerprintBefore fingerprint.Fingerprint
+
+	It("Do a backup", Label("qase-65"), func() {
+		By("Capturing fingerprint of Elemental resources", func() {
+			fingerprintBefore = CaptureFingerprint("before-simple-backup")
+		})
 
 		By("Adding a backup resource", func() {
 			err := kubectl.Apply(clusterNS, backupYaml)
@@ -222,12 +429,13 @@ var _ = Describe("E2E - Test simple Backup/Restore", Label("test-simple-backup-r
 			// Wait for backup to be done
 			CheckBackupRestore("Done with backup")
 		})
-	})
 
-	It("Do a restore", func() {
-		// Report to Qase
-		testCaseID = 66
+		By("Checking backup archive content", func() {
+			checkBackupArchive(getBackupFile(backupResourceName))
+		})
+	})
 
+	It("Do a restore", Label("qase-66"), func() {
 		By("Deleting some Elemental resources", func() {
 			for _, obj := range []string{"MachineRegistration", "MachineInventorySelectorTemplate"} {
 				// List the resources
@@ -277,5 +485,102 @@ var _ = Describe("E2E - Test simple Backup/Restore", Label("test-simple-backup-r
 		By("Checking cluster state after restore", func() {
 			WaitCluster(clusterNS, clusterName)
 		})
+
+		By("Checking that Elemental resources have been restored without drift", func() {
+			Expect(fingerprintBefore).To(Not(BeEmpty()))
+			CheckFingerprint(fingerprintBefore)
+		})
+	})
+})
+
+var _ = Describe("E2E - Test encrypted Backup/Restore", Label("test-encrypted-backup-restore"), func() {
+	const (
+		encryptionSecret      = "elemental-e2e-encryption"
+		restoreName           = "elemental-restore-encrypted"
+		wrongEncryptionSecret = "elemental-e2e-wrong-encryption"
+		wrongRestoreName      = "elemental-restore-wrong-key"
+	)
+
+	// TODO: add a Qase case for the encrypted backup/restore test
+	It("Do an encrypted backup/restore test", Label("qase-none"), func() {
+		var (
+			backupFile        string
+			fingerprintBefore fingerprint.Fingerprint
+		)
+
+		By("Capturing fingerprint of Elemental resources", func() {
+			fingerprintBefore = CaptureFingerprint("before-encrypted-backup")
+		})
+
+		By("Creating the encryption secrets", func() {
+			createEncryptionSecret(encryptionSecret, encryptionKeyName)
+
+			// Same key name but another key, decryption should fail
+			createEncryptionSecret(wrongEncryptionSecret, encryptionKeyName)
+		})
+
+		By("Adding an encrypted backup resource", func() {
+			err := tools.Sed("%ENCRYPTION_SECRET%", encryptionSecret, backupEncryptedYaml)
+			Expect(err).To(Not(HaveOccurred()))
+
+			err = kubectl.Apply(clusterNS, backupEncryptedYaml)
+			Expect(err).To(Not(HaveOccurred()))
+		})
+
+		By("Checking that the backup has been done", func() {
+			CheckBackupRestore("Done with backup")
+			backupFile = getBackupFile(encryptedBackupResourceName)
+		})
+
+		By("Checking that secrets are encrypted in the backup archive", func() {
+			checkEncryptedArchive(backupFile, encryptionKeyName)
+		})
+
+		By("Restoring with a wrong key", func() {
+			applyEncryptedRestore(wrongRestoreName, backupFile, wrongEncryptionSecret)
+
+			// The restore should report an error instead of restoring anything
+			Eventually(func() string {
+				out, _ := kubectl.RunWithoutErr("get", "restore", wrongRestoreName,
+					"-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].status}")
+				return out
+			}, tools.SetTimeout(5*time.Minute), 10*time.Second).Should(Equal("False"))
+
+			out, err := kubectl.RunWithoutErr("get", "restore", wrongRestoreName,
+				"-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].message}")
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(out).To(Not(BeEmpty()))
+			GinkgoWriter.Printf("Restore with wrong key failed with: %s\n", out)
+
+			// Stop the operator from retrying
+			_, err = kubectl.RunWithoutErr("delete", "restore", wrongRestoreName)
+			Expect(err).To(Not(HaveOccurred()))
+		})
+
+		By("Checking that Elemental resources have not been changed by the failed restore", func() {
+			CheckFingerprint(fingerprintBefore)
+		})
+
+		By("Deleting some Elemental resources", func() {
+			for _, obj := range []string{"MachineRegistration", "MachineInventorySelectorTemplate"} {
+				_, err := kubectl.RunWithoutErr("delete", obj, "--namespace", clusterNS, "--all")
+				Expect(err).To(Not(HaveOccurred()))
+			}
+		})
+
+		By("Restoring with the right key", func() {
+			applyEncryptedRestore(restoreName, backupFile, encryptionSecret)
+
+			// Wait for restore to be done
+			CheckBackupRestore("Done restoring")
+		})
+
+		By("Checking cluster state after restore", func() {
+			WaitCluster(clusterNS, clusterName)
+		})
+
+		By("Checking that Elemental resources have been restored without drift", func() {
+			CheckFingerprint(fingerprintBefore)
+		})
 	})
 })
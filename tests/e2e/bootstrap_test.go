@@ -44,10 +44,7 @@ var _ = Describe("E2E - Bootstrapping node", Label("bootstrap"), func() {
 		wg                sync.WaitGroup
 	)
 
-	It("Provision the node", func() {
-		// Report to Qase
-		testCaseID = 9
-
+	It("Provision the node", Label("qase-9"), func() {
 		if !isoBoot && !rawBoot {
 			By("Downloading MachineRegistration file", func() {
 				// Download the new YAML installation config file
@@ -146,10 +143,7 @@ var _ = Describe("E2E - Bootstrapping node", Label("bootstrap"), func() {
 		}
 	})
 
-	It("Add the nodes in Rancher Manager", func() {
-		// Report to Qase
-		testCaseID = 67
-
+	It("Add the nodes in Rancher Manager", Label("qase-67"), func() {
 		for index := vmIndex; index <= numberOfVMs; index++ {
 			// Set node hostname
 			hostName := elemental.SetHostname(vmNameRoot, index)
@@ -30,10 +30,7 @@ import (
 )
 
 var _ = Describe("E2E - Configure test", Label("configure"), func() {
-	It("Deploy a new cluster", func() {
-		// Report to Qase
-		testCaseID = 30
-
+	It("Deploy a new cluster", Label("qase-30"), func() {
 		// Patterns to replace
 		basePatterns := []YamlPattern{
 			{
@@ -182,22 +179,23 @@ var _ = Describe("E2E - Configure test", Label("configure"), func() {
 		})
 	})
 
-	It("Configure Libvirt (if needed)", func() {
-		if !strings.Contains(testType, "airgap") {
-			// Report to Qase
-			testCaseID = 68
+	It("Configure Libvirt (if needed)", Label("qase-68"), func() {
+		if strings.Contains(testType, "airgap") {
+			// Nothing done, so nothing to report to Qase
+			testCaseID = -1
+			return
+		}
 
-			By("Starting default network", func() {
-				// Don't check return code, as the default network could be already removed
-				for _, c := range []string{"net-destroy", "net-undefine"} {
-					_ = exec.Command("sudo", "virsh", c, "default").Run()
-				}
+		By("Starting default network", func() {
+			// Don't check return code, as the default network could be already removed
+			for _, c := range []string{"net-destroy", "net-undefine"} {
+				_ = exec.Command("sudo", "virsh", c, "default").Run()
+			}
 
-				// Wait a bit between virsh commands
-				time.Sleep(30 * time.Second)
-				err := exec.Command("sudo", "virsh", "net-create", netDefaultFileName).Run()
-				Expect(err).To(Not(HaveOccurred()))
-			})
-		}
+			// Wait a bit between virsh commands
+			time.Sleep(30 * time.Second)
+			err := exec.Command("sudo", "virsh", "net-create", netDefaultFileName).Run()
+			Expect(err).To(Not(HaveOccurred()))
+		})
 	})
 })
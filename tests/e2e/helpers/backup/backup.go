@@ -0,0 +1,188 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Entry is a resource stored in a rancher-backup archive
+type Entry struct {
+	// Plural name of the resource, e.g. machineinventories
+	Resource string
+	// API group, empty for core resources
+	Group     string
+	Version   string
+	Namespace string
+	Name      string
+	Data      []byte
+}
+
+/*
+Get the full resource name, as used by kubectl
+  - @returns The resource name with its group, e.g. machineinventories.elemental.cattle.io
+*/
+func (e Entry) FullResource() string {
+	if e.Group == "" {
+		return e.Resource
+	}
+
+	return e.Resource + "." + e.Group
+}
+
+/*
+Parse the path of a file in a rancher-backup archive
+  - @param p Path in the archive, e.g. secrets.#v1/fleet-default/name.json
+  - @returns The entry (without data) and true if the path is a resource
+*/
+func ParsePath(p string) (Entry, bool) {
+	p = strings.TrimPrefix(path.Clean(p), "./")
+	if !strings.HasSuffix(p, ".json") {
+		return Entry{}, false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(p, ".json"), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Entry{}, false
+	}
+
+	// First directory is <resource>.<group>#<version>
+	gr, version, found := strings.Cut(parts[0], "#")
+	if !found {
+		return Entry{}, false
+	}
+	resource, group, _ := strings.Cut(gr, ".")
+
+	e := Entry{
+		Resource: resource,
+		Group:    group,
+		Version:  version,
+		Name:     parts[len(parts)-1],
+	}
+	if len(parts) == 3 {
+		e.Namespace = parts[1]
+	}
+
+	return e, true
+}
+
+/*
+Read a rancher-backup archive
+  - @param r Content of the archive (tar.gz)
+  - @returns The resources found in the archive or an error
+*/
+func Read(r io.Reader) ([]Entry, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var entries []Entry
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		e, ok := ParsePath(hdr.Name)
+		if !ok {
+			continue
+		}
+
+		if e.Data, err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+/*
+Count the resources of a specific type
+  - @param entries Resources of the archive
+  - @param fullResource Resource name with its group, e.g. machineinventories.elemental.cattle.io
+  - @returns Number of resources
+*/
+func Count(entries []Entry, fullResource string) int {
+	n := 0
+	for _, e := range entries {
+		if e.FullResource() == fullResource {
+			n++
+		}
+	}
+
+	return n
+}
+
+/*
+Check if a resource is in the archive
+  - @param entries Resources of the archive
+  - @param fullResource Resource name with its group
+  - @param name Name of the resource
+  - @returns true if the resource is found
+*/
+func Has(entries []Entry, fullResource, name string) bool {
+	for _, e := range entries {
+		if e.FullResource() == fullResource && e.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Count the secrets with a type starting with a prefix
+  - @param entries Resources of the archive
+  - @param typePrefix Prefix of the secret type, e.g. elemental.cattle.io/
+  - @returns Number of secrets or an error if a secret cannot be decoded
+*/
+func CountSecrets(entries []Entry, typePrefix string) (int, error) {
+	n := 0
+	for _, e := range entries {
+		if e.FullResource() != "secrets" {
+			continue
+		}
+
+		var secret struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(e.Data, &secret); err != nil {
+			return 0, err
+		}
+
+		if strings.HasPrefix(secret.Type, typePrefix) {
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/backup"
+)
+
+// Create a tar.gz archive in memory
+func createArchive(files map[string]string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		Expect(err).To(Not(HaveOccurred()))
+		_, err = tw.Write([]byte(content))
+		Expect(err).To(Not(HaveOccurred()))
+	}
+
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+
+	return buf
+}
+
+var _ = Describe("Backup tests", func() {
+	DescribeTable("Parse archive paths",
+		func(p string, expected backup.Entry, ok bool) {
+			e, found := backup.ParsePath(p)
+			Expect(found).To(Equal(ok))
+			Expect(e).To(Equal(expected))
+		},
+		Entry("namespaced resource", "machineinventories.elemental.cattle.io#v1beta1/fleet-default/node-1.json",
+			backup.Entry{Resource: "machineinventories", Group: "elemental.cattle.io", Version: "v1beta1", Namespace: "fleet-default", Name: "node-1"}, true),
+		Entry("core resource", "secrets.#v1/fleet-default/plan.json",
+			backup.Entry{Resource: "secrets", Version: "v1", Namespace: "fleet-default", Name: "plan"}, true),
+		Entry("cluster resource", "customresourcedefinitions.apiextensions.k8s.io#v1/seedimages.elemental.cattle.io.json",
+			backup.Entry{Resource: "customresourcedefinitions", Group: "apiextensions.k8s.io", Version: "v1", Name: "seedimages.elemental.cattle.io"}, true),
+		Entry("filters", "filters/filters.json", backup.Entry{}, false),
+		Entry("not a json file", "README", backup.Entry{}, false),
+	)
+
+	It("Read an archive", func() {
+		archive := createArchive(map[string]string{
+			"machineinventories.elemental.cattle.io#v1beta1/fleet-default/node-1.json": "{}",
+			"machineinventories.elemental.cattle.io#v1beta1/fleet-default/node-2.json": "{}",
+			"secrets.#v1/fleet-default/plan.json":                                      `{"type": "elemental.cattle.io/plan"}`,
+			"secrets.#v1/fleet-default/other.json":                                     `{"type": "Opaque"}`,
+			"filters/filters.json":                                                     "{}",
+		})
+
+		entries, err := backup.Read(archive)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(entries).To(HaveLen(4))
+
+		Expect(backup.Count(entries, "machineinventories.elemental.cattle.io")).To(Equal(2))
+		Expect(backup.Has(entries, "machineinventories.elemental.cattle.io", "node-2")).To(BeTrue())
+		Expect(backup.Has(entries, "seedimages.elemental.cattle.io", "node-2")).To(BeFalse())
+
+		secrets, err := backup.CountSecrets(entries, "elemental.cattle.io/")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(secrets).To(Equal(1))
+	})
+})
+
+var _ = Describe("Encryption tests", func() {
+	It("Generate an encryption configuration", func() {
+		config, err := backup.GenerateEncryptionConfig("elemental")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(config)).To(ContainSubstring("kind: EncryptionConfiguration"))
+		Expect(string(config)).To(ContainSubstring("name: elemental"))
+
+		// Keys must be different each time
+		other, err := backup.GenerateEncryptionConfig("elemental")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(other).To(Not(Equal(config)))
+	})
+
+	DescribeTable("Check encrypted resources",
+		func(data string, encrypted bool) {
+			e := backup.Entry{Resource: "secrets", Version: "v1", Name: "token", Data: []byte(data)}
+			Expect(backup.IsEncrypted(e, "elemental")).To(Equal(encrypted))
+		},
+		// base64 of "k8s:enc:aescbc:v1:elemental:<ciphertext>"
+		Entry("ciphertext", `"azhzOmVuYzphZXNjYmM6djE6ZWxlbWVudGFsOjxjaXBoZXJ0ZXh0Pg=="`, true),
+		// base64 of "k8s:enc:aescbc:v1:other:<ciphertext>"
+		Entry("other key", `"azhzOmVuYzphZXNjYmM6djE6b3RoZXI6PGNpcGhlcnRleHQ+"`, false),
+		Entry("plaintext", `{"type": "elemental.cattle.io/plan"}`, false),
+	)
+})
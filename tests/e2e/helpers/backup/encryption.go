@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// EncryptionConfigKey is the key of the EncryptionConfiguration in the secret used by rancher-backup
+	EncryptionConfigKey = "encryption-provider-config.yaml"
+	// Prefix added by the aescbc provider to each encrypted resource
+	aescbcPrefix = "k8s:enc:aescbc:v1:"
+)
+
+/*
+Generate an EncryptionConfiguration with a random aescbc key for secrets
+  - @param keyName Name of the key, stored in clear in the encrypted resources
+  - @returns The EncryptionConfiguration in YAML format or an error
+*/
+func GenerateEncryptionConfig(keyName string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	config := fmt.Sprintf(`apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+  - resources:
+      - secrets
+    providers:
+      - aescbc:
+          keys:
+            - name: %s
+              secret: %s
+      - identity: {}
+`, keyName, base64.StdEncoding.EncodeToString(key))
+
+	return []byte(config), nil
+}
+
+/*
+Check if a resource of the archive is encrypted
+  - @param e Resource of the archive
+  - @param keyName Name of the key expected to be used
+  - @returns true if the resource only contains ciphertext made with the key
+*/
+func IsEncrypted(e Entry, keyName string) bool {
+	// rancher-backup stores encrypted resources as a JSON (base64) string
+	var data []byte
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(data, []byte(aescbcPrefix+keyName+":"))
+}
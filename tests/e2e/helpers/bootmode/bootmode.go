@@ -0,0 +1,305 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootmode
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher-sandbox/ele-testhelpers/helpers"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+// Mode is a boot entry of an Elemental node
+type Mode string
+
+const (
+	// Active is the default boot entry
+	Active Mode = "active"
+	// Passive is the fallback boot entry (previous snapshot)
+	Passive Mode = "passive"
+	// Recovery is the recovery boot entry
+	Recovery Mode = "recovery"
+
+	// Directory where the mode markers are created at boot
+	markerDir = "/run/elemental"
+	// Grub environment file where the next entry is set
+	grubEnv = "/oem/grubenv"
+)
+
+var (
+	// elemental.image=<mode> is set by all recent Elemental grub entries
+	cmdlineImageRegex = regexp.MustCompile(`(?:^|\s)elemental\.image=(\w+)`)
+	// Older cOS based images use the image file name instead
+	cmdlineCOSRegex = regexp.MustCompile(`(?:^|\s)cos-img/filename=\S*/(\w+)\.img`)
+	// Marker files are named <mode>_mode
+	markerRegex = regexp.MustCompile(`(?:^|/)(\w+)_mode$`)
+)
+
+/*
+Check if a mode is a known boot mode
+  - @returns true if the mode is active, passive or recovery
+*/
+func (m Mode) IsValid() bool {
+	return slices.Contains([]Mode{Active, Passive, Recovery}, m)
+}
+
+/*
+Get the mode set in the kernel command line
+  - @param cmdline Content of /proc/cmdline
+  - @returns The boot mode or an error if no mode is found
+*/
+func ParseCmdline(cmdline string) (Mode, error) {
+	for _, r := range []*regexp.Regexp{cmdlineImageRegex, cmdlineCOSRegex} {
+		if m := r.FindStringSubmatch(cmdline); m != nil {
+			if mode := Mode(m[1]); mode.IsValid() {
+				return mode, nil
+			}
+			return "", fmt.Errorf("unknown boot mode %q in cmdline", m[1])
+		}
+	}
+
+	return "", fmt.Errorf("no boot mode found in cmdline %q", strings.TrimSpace(cmdline))
+}
+
+/*
+Get the modes from the marker files
+  - @param list List of files in /run/elemental
+  - @returns The modes found, sorted by name
+*/
+func ParseMarkers(list string) []Mode {
+	var modes []Mode
+	for _, f := range strings.Fields(list) {
+		if m := markerRegex.FindStringSubmatch(f); m != nil {
+			if mode := Mode(m[1]); mode.IsValid() {
+				modes = append(modes, mode)
+			}
+		}
+	}
+	slices.Sort(modes)
+
+	return modes
+}
+
+/*
+Check that marker files and kernel command line agree on the boot mode
+  - @param markers List of files in /run/elemental
+  - @param cmdline Content of /proc/cmdline
+  - @returns The boot mode or an error if both sources are not consistent
+*/
+func Resolve(markers, cmdline string) (Mode, error) {
+	modes := ParseMarkers(markers)
+	if len(modes) != 1 {
+		return "", fmt.Errorf("expected exactly one boot mode marker, found %v", modes)
+	}
+
+	mode, err := ParseCmdline(cmdline)
+	if err != nil {
+		return "", err
+	}
+
+	if mode != modes[0] {
+		return "", fmt.Errorf("boot mode marker %q does not match cmdline mode %q", modes[0], mode)
+	}
+
+	return mode, nil
+}
+
+/*
+Get the current boot mode of a node
+  - @param cl Client (node) informations
+  - @returns The boot mode or an error
+*/
+func Current(cl *tools.Client) (Mode, error) {
+	markers, err := cl.RunSSH("ls -1 " + markerDir)
+	if err != nil {
+		return "", err
+	}
+
+	cmdline, err := cl.RunSSH("cat /proc/cmdline")
+	if err != nil {
+		return "", err
+	}
+
+	return Resolve(markers, cmdline)
+}
+
+/*
+Select the entry used at next boot only
+  - @param cl Client (node) informations
+  - @param mode Boot mode to use
+  - @returns Nothing or an error
+*/
+func SetNext(cl *tools.Client, mode Mode) error {
+	if !mode.IsValid() {
+		return fmt.Errorf("unknown boot mode %q", mode)
+	}
+
+	if _, err := cl.RunSSH("grub2-editenv " + grubEnv + " set next_entry=" + string(mode)); err != nil {
+		return err
+	}
+
+	// Check that the entry is selected
+	out, err := cl.RunSSH("grub2-editenv " + grubEnv + " list")
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(strings.Fields(out), "next_entry="+string(mode)) {
+		return fmt.Errorf("next_entry not set to %q in %s", mode, grubEnv)
+	}
+
+	return nil
+}
+
+/*
+Get the boot ID of a node, it changes at each boot
+  - @param cl Client (node) informations
+  - @returns The boot ID or an error
+*/
+func BootID(cl *tools.Client) (string, error) {
+	out, err := cl.RunSSH("cat /proc/sys/kernel/random/boot_id")
+	return strings.TrimSpace(out), err
+}
+
+/*
+Wait for a node to be rebooted in a specific mode
+  - @param cl Client (node) informations
+  - @param bootID Boot ID before the reboot
+  - @param mode Expected boot mode
+  - @param timeout Maximum time to wait
+  - @returns Nothing or an error
+*/
+func WaitForMode(cl *tools.Client, bootID string, mode Mode, timeout time.Duration) error {
+	var current Mode
+	var lastErr error
+
+	err := helpers.PollImmediate(10*time.Second, timeout, func() (bool, error) {
+		// SSH errors are expected while the node is rebooting
+		id, err := BootID(cl)
+		if err != nil || id == "" || id == bootID {
+			return false, nil
+		}
+
+		current, lastErr = Current(cl)
+		return lastErr == nil && current == mode, nil
+	})
+	if err != nil {
+		return fmt.Errorf("node not booted in %q mode (current: %q, last error: %v): %w", mode, current, lastErr, err)
+	}
+
+	return nil
+}
+
+/*
+Reboot a node in a specific mode
+  - @param cl Client (node) informations
+  - @param mode Boot mode to use
+  - @param timeout Maximum time to wait for the node to be back
+  - @returns Nothing or an error
+*/
+func BootInto(cl *tools.Client, mode Mode, timeout time.Duration) error {
+	bootID, err := BootID(cl)
+	if err != nil {
+		return err
+	}
+
+	if err := SetNext(cl, mode); err != nil {
+		return err
+	}
+
+	// Execute 'reboot' in background, to avoid SSH locking
+	if _, err := cl.RunSSH("setsid -f reboot"); err != nil {
+		return err
+	}
+
+	return WaitForMode(cl, bootID, mode, timeout)
+}
+
+/*
+Reboot a node in the default (active) mode and clean the grub environment
+  - @param cl Client (node) informations
+  - @param timeout Maximum time to wait for the node to be back
+  - @returns Nothing or an error
+*/
+func Restore(cl *tools.Client, timeout time.Duration) error {
+	if err := BootInto(cl, Active, timeout); err != nil {
+		return err
+	}
+
+	// next_entry is normally removed by grub at boot, but make sure it is
+	_, err := cl.RunSSH("grub2-editenv " + grubEnv + " unset next_entry")
+	return err
+}
+
+/*
+Reset a node from recovery mode, as the reset service started by the operator would do
+  - @param cl Client (node) informations
+  - @param timeout Maximum time to wait for the node to be back in active mode
+  - @returns Nothing or an error
+*/
+func ResetFromRecovery(cl *tools.Client, timeout time.Duration) error {
+	mode, err := Current(cl)
+	if err != nil {
+		return err
+	}
+	if mode != Recovery {
+		return fmt.Errorf("reset can only be done from recovery mode, current mode is %q", mode)
+	}
+
+	bootID, err := BootID(cl)
+	if err != nil {
+		return err
+	}
+
+	// Reset config (persistent and OEM wipe, reboot) is sent by the operator,
+	// takes time so execute it in background to avoid SSH timeout
+	if _, err := cl.RunSSH("setsid -f sh -c 'elemental-register --debug --reset > /tmp/elemental-reset.log 2>&1'"); err != nil {
+		return err
+	}
+
+	return WaitForMode(cl, bootID, Active, timeout)
+}
+
+/*
+Upgrade the active system from recovery mode, to replace a broken active snapshot
+  - @param cl Client (node) informations
+  - @param image Container image of the system to install
+  - @param timeout Maximum time to wait for the node to be back in active mode
+  - @returns Nothing or an error
+*/
+func UpgradeFromRecovery(cl *tools.Client, image string, timeout time.Duration) error {
+	mode, err := Current(cl)
+	if err != nil {
+		return err
+	}
+	if mode != Recovery {
+		return fmt.Errorf("active system can only be upgraded from recovery mode, current mode is %q", mode)
+	}
+
+	bootID, err := BootID(cl)
+	if err != nil {
+		return err
+	}
+
+	// Upgrade takes time, execute it in background to avoid SSH timeout
+	if _, err := cl.RunSSH("setsid -f sh -c 'elemental upgrade --system docker:" + image + " --reboot > /tmp/elemental-upgrade.log 2>&1'"); err != nil {
+		return err
+	}
+
+	return WaitForMode(cl, bootID, Active, timeout)
+}
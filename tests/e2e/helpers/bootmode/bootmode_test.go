@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootmode_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/bootmode"
+)
+
+var _ = Describe("Boot mode tests", func() {
+	DescribeTable("Parse kernel cmdline",
+		func(cmdline string, expected bootmode.Mode) {
+			mode, err := bootmode.ParseCmdline(cmdline)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(mode).To(Equal(expected))
+		},
+		Entry("active", "BOOT_IMAGE=(loop0)/boot/vmlinuz console=tty1 root=LABEL=COS_STATE elemental.image=active elemental.oemlabel=COS_OEM", bootmode.Active),
+		Entry("passive", "root=LABEL=COS_STATE elemental.image=passive", bootmode.Passive),
+		Entry("recovery", "root=LABEL=COS_RECOVERY elemental.image=recovery rd.neednet=0", bootmode.Recovery),
+		Entry("old cOS image", "root=LABEL=COS_STATE cos-img/filename=/cOS/active.img panic=5", bootmode.Active),
+	)
+
+	It("Reject unknown cmdline", func() {
+		_, err := bootmode.ParseCmdline("root=LABEL=COS_STATE console=tty1")
+		Expect(err).To(HaveOccurred())
+
+		_, err = bootmode.ParseCmdline("elemental.image=foo")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Parse marker files", func() {
+		Expect(bootmode.ParseMarkers("active_mode\nboot_assessment\nrecovery_mode\n")).To(Equal([]bootmode.Mode{bootmode.Active, bootmode.Recovery}))
+		Expect(bootmode.ParseMarkers("/run/elemental/passive_mode")).To(Equal([]bootmode.Mode{bootmode.Passive}))
+		Expect(bootmode.ParseMarkers("")).To(BeEmpty())
+	})
+
+	It("Resolve boot mode", func() {
+		mode, err := bootmode.Resolve("recovery_mode\n", "elemental.image=recovery")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(mode).To(Equal(bootmode.Recovery))
+
+		// Marker and cmdline do not agree
+		_, err = bootmode.Resolve("active_mode\n", "elemental.image=passive")
+		Expect(err).To(HaveOccurred())
+
+		// No marker
+		_, err = bootmode.Resolve("", "elemental.image=active")
+		Expect(err).To(HaveOccurred())
+	})
+})
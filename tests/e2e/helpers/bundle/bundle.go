@@ -0,0 +1,221 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ManifestFile is the name of the manifest in the bundle
+const ManifestFile = "manifest.json"
+
+// Item describes a file of the bundle
+type Item struct {
+	Path string `json:"path"`
+	// Command or location the data comes from
+	Source string `json:"source"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Set if the data cannot be collected, the file is not in the bundle then
+	Error string `json:"error,omitempty"`
+}
+
+// Manifest lists all the items of the bundle
+type Manifest struct {
+	Items []Item `json:"items"`
+}
+
+// Bundle gathers data to be written in a tarball, it is safe for concurrent use
+type Bundle struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	items map[string]Item
+}
+
+/*
+Create an empty bundle
+  - @returns The bundle
+*/
+func New() *Bundle {
+	return &Bundle{
+		files: map[string][]byte{},
+		items: map[string]Item{},
+	}
+}
+
+/*
+Add data to the bundle
+  - @param p Path of the file in the bundle
+  - @param source Command or location the data comes from
+  - @param data Content of the file
+  - @returns Nothing, an existing file with the same path is replaced
+*/
+func (b *Bundle) Add(p, source string, data []byte) {
+	p = path.Clean(p)
+	sum := sha256.Sum256(data)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.files[p] = data
+	b.items[p] = Item{
+		Path:   p,
+		Source: source,
+		Size:   len(data),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+}
+
+/*
+Record in the manifest that some data cannot be collected
+  - @param p Path the file would have in the bundle
+  - @param source Command or location the data comes from
+  - @param err Error returned when collecting the data
+  - @returns Nothing
+*/
+func (b *Bundle) AddError(p, source string, err error) {
+	p = path.Clean(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.files, p)
+	b.items[p] = Item{
+		Path:   p,
+		Source: source,
+		Error:  err.Error(),
+	}
+}
+
+/*
+Collect data and add it to the bundle
+  - @param p Path of the file in the bundle
+  - @param source Command or location the data comes from
+  - @param f Function returning the data
+  - @returns The error returned by f, also recorded in the manifest
+*/
+func (b *Bundle) Collect(p, source string, f func() (string, error)) error {
+	out, err := f()
+	if err != nil {
+		b.AddError(p, source, err)
+		return err
+	}
+
+	b.Add(p, source, []byte(out))
+	return nil
+}
+
+/*
+Get the manifest of the bundle
+  - @returns The manifest, items are sorted by path
+*/
+func (b *Bundle) Manifest() Manifest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := Manifest{Items: make([]Item, 0, len(b.items))}
+	for _, i := range b.items {
+		m.Items = append(m.Items, i)
+	}
+	sort.Slice(m.Items, func(i, j int) bool { return m.Items[i].Path < m.Items[j].Path })
+
+	return m
+}
+
+/*
+Write the bundle as a tarball
+  - @param w Writer for the tar.gz data
+  - @returns Nothing or an error
+*/
+func (b *Bundle) Write(w io.Writer) error {
+	manifest, err := json.MarshalIndent(b.Manifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	// Fixed metadata, the same content always gives the same tarball
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  time.Unix(0, 0),
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add(ManifestFile, manifest); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	paths := make([]string, 0, len(b.files))
+	for p := range b.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if err := add(p, b.files[p]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+/*
+Write the bundle in a file
+  - @param file Name of the tar.gz file
+  - @returns Nothing or an error
+*/
+func (b *Bundle) WriteFile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
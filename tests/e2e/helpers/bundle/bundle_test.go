@@ -0,0 +1,108 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/bundle"
+)
+
+// Read all files of a tar.gz archive, in order
+func readArchive(data []byte) ([]string, map[string][]byte) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	Expect(err).To(Not(HaveOccurred()))
+
+	var names []string
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		Expect(err).To(Not(HaveOccurred()))
+
+		content, err := io.ReadAll(tr)
+		Expect(err).To(Not(HaveOccurred()))
+		names = append(names, hdr.Name)
+		files[hdr.Name] = content
+	}
+
+	return names, files
+}
+
+var _ = Describe("Bundle tests", func() {
+	fill := func(b *bundle.Bundle) {
+		b.Add("logs/cattle-system/rancher/rancher.log", "kubectl logs", []byte("started"))
+		b.Add("elemental/machineinventories.elemental.cattle.io.yaml", "kubectl get", []byte("items: []"))
+		_ = b.Collect("helm/values.yaml", "helm get values", func() (string, error) {
+			return "", errors.New("helm not found")
+		})
+	}
+
+	It("Write a bundle with a manifest", func() {
+		b := bundle.New()
+		fill(b)
+
+		var buf bytes.Buffer
+		Expect(b.Write(&buf)).To(Succeed())
+
+		names, files := readArchive(buf.Bytes())
+		Expect(names).To(Equal([]string{
+			bundle.ManifestFile,
+			"elemental/machineinventories.elemental.cattle.io.yaml",
+			"logs/cattle-system/rancher/rancher.log",
+		}))
+		Expect(string(files["logs/cattle-system/rancher/rancher.log"])).To(Equal("started"))
+
+		var m bundle.Manifest
+		Expect(json.Unmarshal(files[bundle.ManifestFile], &m)).To(Succeed())
+		Expect(m.Items).To(HaveLen(3))
+		Expect(m.Items[1].Path).To(Equal("helm/values.yaml"))
+		Expect(m.Items[1].Error).To(Equal("helm not found"))
+		Expect(m.Items[2].Size).To(Equal(7))
+		// sha256 of "started"
+		Expect(m.Items[2].SHA256).To(Equal("03494afd4248c42f5fa1237bf2eeebe751ab8d9c977d55405fcb17469dbd91f8"))
+	})
+
+	It("Write reproducible bundles", func() {
+		first, second := bundle.New(), bundle.New()
+		fill(first)
+		fill(second)
+
+		var a, b bytes.Buffer
+		Expect(first.Write(&a)).To(Succeed())
+		Expect(second.Write(&b)).To(Succeed())
+		Expect(a.Bytes()).To(Equal(b.Bytes()))
+	})
+
+	It("Replace a failed collection", func() {
+		b := bundle.New()
+		b.AddError("events.txt", "kubectl get events", errors.New("timeout"))
+		b.Add("events.txt", "kubectl get events", []byte("no events"))
+
+		m := b.Manifest()
+		Expect(m.Items).To(HaveLen(1))
+		Expect(m.Items[0].Error).To(BeEmpty())
+	})
+})
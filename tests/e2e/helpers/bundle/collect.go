@@ -0,0 +1,177 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+// DefaultNamespaces are the namespaces with the pod logs to collect
+var DefaultNamespaces = []string{
+	// Elemental operator
+	"cattle-elemental-system",
+	// SeedImage builders
+	"fleet-default",
+	// Rancher Manager
+	"cattle-system",
+	// Fleet controllers
+	"cattle-fleet-system",
+	"cattle-fleet-local-system",
+	// CAPI controllers
+	"cattle-provisioning-capi-system",
+}
+
+// Add the output of a kubectl command in the bundle
+func (b *Bundle) kubectl(p string, args ...string) error {
+	return b.Collect(p, "kubectl "+strings.Join(args, " "), func() (string, error) {
+		return kubectl.RunWithoutErr(args...)
+	})
+}
+
+/*
+Collect all Elemental resources
+  - @returns Nothing or the errors found, also recorded in the manifest
+*/
+func (b *Bundle) CollectElementalResources() error {
+	out, err := kubectl.RunWithoutErr("get", "crd", "-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		b.AddError("elemental", "kubectl get crd", err)
+		return err
+	}
+
+	var errs []error
+	for _, crd := range strings.Fields(out) {
+		if strings.HasSuffix(crd, ".elemental.cattle.io") {
+			errs = append(errs, b.kubectl(path.Join("elemental", crd+".yaml"),
+				"get", crd, "--all-namespaces", "-o", "yaml"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Collect logs of all containers of all pods
+  - @param namespaces Namespaces where the pods are running
+  - @returns Nothing or the errors found, also recorded in the manifest
+*/
+func (b *Bundle) CollectPodLogs(namespaces ...string) error {
+	var errs []error
+	for _, ns := range namespaces {
+		out, err := kubectl.RunWithoutErr("get", "pods", "--namespace", ns,
+			"-o", "jsonpath={range .items[*]}{.metadata.name}{\" \"}{.spec.containers[*].name}{\"\\n\"}{end}")
+		if err != nil {
+			b.AddError(path.Join("logs", ns), "kubectl get pods --namespace "+ns, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+
+			pod := fields[0]
+			for _, container := range fields[1:] {
+				errs = append(errs, b.kubectl(path.Join("logs", ns, pod, container+".log"),
+					"logs", "--namespace", ns, pod, "--container", container, "--timestamps"))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Collect events of all namespaces
+  - @returns Nothing or an error, also recorded in the manifest
+*/
+func (b *Bundle) CollectEvents() error {
+	return b.kubectl("events.txt", "get", "events", "--all-namespaces",
+		"--sort-by=.lastTimestamp", "-o", "wide")
+}
+
+/*
+Collect values of all Helm releases
+  - @returns Nothing or the errors found, also recorded in the manifest
+*/
+func (b *Bundle) CollectHelmReleases() error {
+	out, err := exec.Command("helm", "list", "--all-namespaces", "-o", "json").Output()
+	if err != nil {
+		b.AddError("helm", "helm list --all-namespaces", err)
+		return err
+	}
+
+	var releases []struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		Chart     string `json:"chart"`
+	}
+	if err := json.Unmarshal(out, &releases); err != nil {
+		b.AddError("helm", "helm list --all-namespaces", err)
+		return err
+	}
+
+	var errs []error
+	for _, r := range releases {
+		args := []string{"get", "values", r.Name, "--namespace", r.Namespace, "--all", "-o", "yaml"}
+		errs = append(errs, b.Collect(path.Join("helm", r.Namespace, r.Name+"-values.yaml"),
+			"helm "+strings.Join(args, " "), func() (string, error) {
+				out, err := exec.Command("helm", args...).Output()
+				return string(out), err
+			}))
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Collect journal and /oem content of a node
+  - @param name Name of the node
+  - @param cl Client (node) informations
+  - @returns Nothing or the errors found, also recorded in the manifest
+*/
+func (b *Bundle) CollectNode(name string, cl *tools.Client) error {
+	ssh := func(p, cmd string) error {
+		return b.Collect(path.Join("nodes", name, p), "ssh "+name+" "+cmd, func() (string, error) {
+			return cl.RunSSH(cmd)
+		})
+	}
+
+	errs := []error{
+		ssh("journal.log", "journalctl --no-pager --output=short-iso"),
+		ssh("elemental-system-agent.log", "journalctl --no-pager --output=short-iso --unit=elemental-system-agent"),
+	}
+
+	files, err := cl.RunSSH("find /oem -xdev -type f -not -path '/oem/lost+found/*'")
+	if err != nil {
+		b.AddError(path.Join("nodes", name, "oem"), "ssh "+name+" find /oem", err)
+		return errors.Join(append(errs, err)...)
+	}
+
+	for _, f := range strings.Fields(files) {
+		errs = append(errs, ssh(f, "cat "+f))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+)
+
+// Section is a part of the diagnostics
+type Section struct {
+	Name    string
+	Command string
+	Content string
+	// Set if the content cannot be captured
+	Error string
+}
+
+// Snapshot contains the state of the cluster and nodes at a given time
+type Snapshot struct {
+	Sections []Section
+}
+
+// Do not wait too long if the API server is not reachable
+const requestTimeout = "--request-timeout=30s"
+
+// Add the output of a kubectl command in the snapshot
+func (s *Snapshot) kubectl(name string, args ...string) {
+	args = append(args, requestTimeout)
+	out, err := kubectl.RunWithoutErr(args...)
+	s.add(name, "kubectl "+strings.Join(args, " "), out, err)
+}
+
+// Add a section in the snapshot
+func (s *Snapshot) add(name, command, content string, err error) {
+	section := Section{Name: name, Command: command, Content: content}
+	if err != nil {
+		section.Error = err.Error()
+	}
+	s.Sections = append(s.Sections, section)
+}
+
+/*
+Capture the state of the cluster and nodes
+  - @param nodes SSH clients of the nodes, by name
+  - @param lines Number of journal lines to get from each node
+  - @returns The snapshot, sections that cannot be captured contain an error
+*/
+func Capture(nodes map[string]*tools.Client, lines int) Snapshot {
+	var s Snapshot
+
+	s.kubectl("Cluster conditions", "get", "clusters.provisioning.cattle.io", "--all-namespaces",
+		"-o", "jsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name}{\"\\n\"}"+
+			"{range .status.conditions[*]}  {.type}={.status} {.reason} {.message}{\"\\n\"}{end}{end}")
+	s.kubectl("MachineInventories", "get", "machineinventories.elemental.cattle.io", "--all-namespaces",
+		"--show-labels", "-o", "wide")
+	s.kubectl("MachineInventory conditions", "get", "machineinventories.elemental.cattle.io", "--all-namespaces",
+		"-o", "jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}"+
+			"{range .status.conditions[*]}  {.type}={.status} {.reason} {.message}{\"\\n\"}{end}{end}")
+	s.kubectl("Machines", "get", "machines.cluster.x-k8s.io", "--all-namespaces", "-o", "wide")
+	s.kubectl("ManagedOSImage status", "get", "managedosimages.elemental.cattle.io", "--all-namespaces",
+		"-o", "jsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name}{\"\\n\"}{.status}{\"\\n\"}{end}")
+
+	// Sort nodes to always get the same order
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmd := "journalctl --no-pager --output=short-iso --lines=" + strconv.Itoa(lines)
+	for _, name := range names {
+		out, err := nodes[name].RunSSH(cmd)
+		s.add("Journal of "+name, "ssh "+name+" "+cmd, out, err)
+	}
+
+	return s
+}
+
+/*
+Get the snapshot in text format
+  - @returns All sections with their content or error
+*/
+func (s Snapshot) String() string {
+	var b strings.Builder
+	for _, section := range s.Sections {
+		fmt.Fprintf(&b, "===== %s =====\n", section.Name)
+		fmt.Fprintf(&b, "$ %s\n", section.Command)
+		if section.Error != "" {
+			fmt.Fprintf(&b, "ERROR: %s\n", section.Error)
+		}
+		if section.Content != "" {
+			b.WriteString(strings.TrimSuffix(section.Content, "\n") + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+/*
+Write the snapshot in a file
+  - @param file Name of the file
+  - @returns Nothing or an error
+*/
+func (s Snapshot) WriteFile(file string) error {
+	return os.WriteFile(file, []byte(s.String()), 0644)
+}
+
+/*
+Get a file name from a spec name
+  - @param prefix Prefix of the file name
+  - @param spec Full text of the spec
+  - @returns A file name with only alphanumeric characters and dashes
+*/
+func FileName(prefix, spec string) string {
+	return prefix + "-" + misc.Slug(spec) + ".txt"
+}
@@ -0,0 +1,48 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/diagnostics"
+)
+
+var _ = Describe("Diagnostics tests", func() {
+	It("Format a snapshot", func() {
+		s := diagnostics.Snapshot{Sections: []diagnostics.Section{
+			{Name: "Machines", Command: "kubectl get machines", Content: "node-1 Running\n"},
+			{Name: "Journal of node-1", Command: "ssh node-1 journalctl", Error: "connection refused"},
+		}}
+
+		Expect(s.String()).To(Equal(`===== Machines =====
+$ kubectl get machines
+node-1 Running
+
+===== Journal of node-1 =====
+$ ssh node-1 journalctl
+ERROR: connection refused
+
+`))
+	})
+
+	DescribeTable("Get file name from spec",
+		func(spec, expected string) {
+			Expect(diagnostics.FileName("diagnostics", spec)).To(Equal(expected))
+		},
+		Entry("simple", "E2E - Upgrading node Upgrade node", "diagnostics-e2e-upgrading-node-upgrade-node.txt"),
+		Entry("special characters", "Reset: node #2 (worker)", "diagnostics-reset-node-2-worker.txt"),
+	)
+})
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/onsi/ginkgo/v2/types"
+	"github.com/rancher/elemental/tests/e2e/helpers/qaseapi"
+	qase "go.qase.io/client"
+)
+
+/*
+Send a failed result with diagnostics to Qase
+  - @param id ID of the Qase case
+  - @param report Report of the failed spec
+  - @param file Diagnostics file to attach
+  - @returns Nothing or an error, no result is created in case of error
+*/
+func SendToQase(id int64, report types.SpecReport, file string) error {
+	token := os.Getenv("QASE_API_TOKEN")
+	project := os.Getenv("QASE_PROJECT_CODE")
+	runID, err := strconv.ParseInt(os.Getenv("QASE_RUN_ID"), 10, 32)
+	if err != nil || runID <= 0 || id <= 0 {
+		return fmt.Errorf("no Qase run (QASE_RUN_ID=%q) or wrong case id %d", os.Getenv("QASE_RUN_ID"), id)
+	}
+
+	cfg := qase.NewConfiguration()
+	cfg.AddDefaultHeader("Token", token)
+	client := qase.NewAPIClient(cfg)
+
+	attachment, err := qaseapi.New().Upload(file)
+	if err != nil {
+		return err
+	}
+
+	result := qase.ResultCreate{
+		CaseId:      id,
+		Status:      "failed",
+		TimeMs:      report.RunTime.Milliseconds(),
+		Attachments: []string{attachment.Hash},
+		Stacktrace:  report.Failure.Location.FullStackTrace,
+		Comment:     report.Failure.Message + "\n\nFailed at: " + report.Failure.Location.String(),
+	}
+	_, _, err = client.ResultsApi.CreateResult(context.TODO(), result, project, runID)
+
+	return err
+}
@@ -15,6 +15,7 @@ limitations under the License.
 package elemental
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -22,6 +23,13 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// MachineInventory contains the useful fields of a MachineInventory resource
+type MachineInventory struct {
+	Name   string
+	Labels map[string]string
+	IP     string
+}
+
 /*
 Add node selector
   - @param key key to add in YAML
@@ -65,6 +73,53 @@ func GetClusterState(ns, cluster, condition string) (string, error) {
 	return out, nil
 }
 
+/*
+Get K8s version running on the cluster
+  - @param ns Namespace where the cluster is deployed
+  - @param cluster Name of the cluster to check
+  - @returns The K8s version reported by Rancher (e.g. v1.30.5+rke2r1) or an error
+*/
+func GetClusterVersion(ns, cluster string) (string, error) {
+	// Version is only available in the management cluster
+	id, err := GetClusterState(ns, cluster, "{.status.clusterName}")
+	if err != nil {
+		return "", err
+	}
+
+	return kubectl.RunWithoutErr("get", "clusters.management.cattle.io", id,
+		"-o", "jsonpath={.status.version.gitVersion}")
+}
+
+/*
+Get the cluster of a Machine
+  - @param ns Namespace where the cluster is deployed
+  - @param machine Machine name as seen by Rancher Manager
+  - @returns Name of the cluster owning the Machine or an error
+*/
+func GetMachineCluster(ns, machine string) (string, error) {
+	return kubectl.RunWithoutErr("get", "Machine",
+		"--namespace", ns, machine,
+		"-o", "jsonpath={.metadata.labels.cluster\\.x-k8s\\.io/cluster-name}")
+}
+
+/*
+List the Machines of a cluster
+  - @param ns Namespace where the cluster is deployed
+  - @param cluster Name of the cluster
+  - @returns The names of the Machines or an error
+*/
+func ListClusterMachines(ns, cluster string) ([]string, error) {
+	out, err := kubectl.RunWithoutErr("get", "Machine",
+		"--namespace", ns,
+		"--selector", "cluster.x-k8s.io/cluster-name="+cluster,
+		"-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(out), nil
+}
+
 /*
 Get nodeName from MachineInventory
   - @param ns Namespace
@@ -134,6 +189,61 @@ func GetInternalMachine(ns, machineInventory string) (string, error) {
 	return machine, nil
 }
 
+/*
+List MachineInventories
+  - @param ns Namespace
+  - @param selector Label selector (e.g. pool-type=worker), all MachineInventories if empty
+  - @returns The MachineInventories sorted by name or an error
+*/
+func ListMachineInventories(ns, selector string) ([]MachineInventory, error) {
+	args := []string{"get", "MachineInventory", "--namespace", ns, "-o", "json"}
+	if selector != "" {
+		args = append(args, "--selector", selector)
+	}
+
+	out, err := kubectl.RunWithoutErr(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only needed fields are defined
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Labels      map[string]string `json:"labels"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, err
+	}
+
+	var inventories []MachineInventory
+	for _, item := range list.Items {
+		inventories = append(inventories, MachineInventory{
+			Name:   item.Metadata.Name,
+			Labels: item.Metadata.Labels,
+			IP:     item.Metadata.Annotations["elemental.cattle.io/registration-ip"],
+		})
+	}
+
+	return inventories, nil
+}
+
+/*
+Get TPM hash of a MachineInventory
+  - @param ns Namespace
+  - @param machineInventory Machine name as seen by Elemental
+  - @returns The TPM hash (also set with emulated TPM) or an error
+*/
+func GetMachineInventoryTPMHash(ns, machineInventory string) (string, error) {
+	return kubectl.RunWithoutErr("get", "MachineInventory",
+		"--namespace", ns, machineInventory,
+		"-o", "jsonpath={.spec.tpmHash}")
+}
+
 /*
 Get container image used for Elemental operator
   - @returns The container image used or an error
@@ -0,0 +1,99 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elemental
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+)
+
+// ResetService is the systemd service executing the reset in recovery mode
+const ResetService = "elemental-register-reset.service"
+
+// ServiceTimes contains the state and timestamps of a systemd service
+type ServiceTimes struct {
+	State string
+	Start time.Time
+	Exit  time.Time
+}
+
+/*
+List the files in the OEM partition
+  - @param cl Client (node) informations
+  - @returns The files, relative to /oem and sorted, or an error
+*/
+func ListOEMFiles(cl *tools.Client) ([]string, error) {
+	out, err := cl.RunSSH("find /oem -path /oem/lost+found -prune -o -type f -printf '%P\\n'")
+	if err != nil {
+		return nil, err
+	}
+
+	files := strings.Fields(out)
+	slices.Sort(files)
+
+	return files, nil
+}
+
+/*
+Get the state and timestamps of a systemd service
+  - @param cl Client (node) informations
+  - @param service Name of the service
+  - @returns The service times (zero if not set) or an error
+*/
+func GetServiceTimes(cl *tools.Client, service string) (ServiceTimes, error) {
+	out, err := cl.RunSSH("systemctl show " + service +
+		" --property=ActiveState,ExecMainStartTimestamp,ExecMainExitTimestamp")
+	if err != nil {
+		return ServiceTimes{}, err
+	}
+
+	properties := misc.ParseSystemdProperties(out)
+
+	start, err := misc.ParseSystemdTimestamp(properties["ExecMainStartTimestamp"])
+	if err != nil {
+		return ServiceTimes{}, err
+	}
+
+	exit, err := misc.ParseSystemdTimestamp(properties["ExecMainExitTimestamp"])
+	if err != nil {
+		return ServiceTimes{}, err
+	}
+
+	return ServiceTimes{
+		State: properties["ActiveState"],
+		Start: start,
+		Exit:  exit,
+	}, nil
+}
+
+/*
+Check if the Kubernetes API of the downstream cluster answers on a master node
+  - @param cl Client (node) informations
+  - @returns true if /readyz is ok or an error
+*/
+func IsAPIReady(cl *tools.Client) (bool, error) {
+	// Do not rely on the kubectl configuration done at bootstrap, it is removed by a reset
+	out, err := cl.RunSSH("KUBECONFIG=$(ls /etc/rancher/rke2/rke2.yaml /etc/rancher/k3s/k3s.yaml 2>/dev/null | head -1)" +
+		" PATH=${PATH}:/var/lib/rancher/rke2/bin:/usr/local/bin kubectl get --raw /readyz")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(out) == "ok", nil
+}
@@ -0,0 +1,293 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package elemental
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher-sandbox/ele-testhelpers/helpers"
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher/elemental/tests/e2e/helpers/upgrade"
+)
+
+const (
+	// Label set by the operator on each synced ManagedOSVersion
+	channelLabel = "elemental.cattle.io/channel"
+
+	// Interval used to force a channel synchronization, only if syncInterval is too long
+	forcedSyncInterval = "1m"
+
+	// OSVersionContainer is the type of ManagedOSVersion used for upgrades
+	OSVersionContainer = "container"
+	// OSVersionISO is the type of ManagedOSVersion used to build ISO images
+	OSVersionISO = "iso"
+)
+
+// ManagedOSVersion contains the useful fields of a ManagedOSVersion resource
+type ManagedOSVersion struct {
+	Name         string
+	Channel      string
+	DisplayName  string
+	Type         string
+	Version      string
+	UpgradeImage string
+	URI          string
+}
+
+// OSVersionQuery defines which ManagedOSVersions to select
+type OSVersionQuery struct {
+	// Name of the ManagedOSVersionChannel, any channel if empty
+	Channel string
+	// Type of ManagedOSVersion (OSVersionContainer or OSVersionISO), any type if empty
+	Type string
+	// Select stable or unstable versions
+	Stable bool
+}
+
+// JSON structure returned by kubectl, only needed fields are defined
+type managedOSVersionList struct {
+	Items []struct {
+		Metadata struct {
+			Name            string            `json:"name"`
+			Labels          map[string]string `json:"labels"`
+			OwnerReferences []struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"ownerReferences"`
+		} `json:"metadata"`
+		Spec struct {
+			Type     string `json:"type"`
+			Version  string `json:"version"`
+			Metadata struct {
+				DisplayName  string `json:"displayName"`
+				UpgradeImage string `json:"upgradeImage"`
+				URI          string `json:"uri"`
+			} `json:"metadata"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+/*
+Check if a ManagedOSVersion is a stable one
+  - @returns true if the display name does not contain 'unstable'
+*/
+func (v ManagedOSVersion) IsStable() bool {
+	return !strings.Contains(strings.ToLower(v.DisplayName), "unstable")
+}
+
+/*
+Check if a ManagedOSVersion matches a query
+  - @param q Query to check
+  - @returns true if all the query fields match
+*/
+func (v ManagedOSVersion) Matches(q OSVersionQuery) bool {
+	if q.Channel != "" && q.Channel != v.Channel {
+		return false
+	}
+
+	if q.Type != "" && q.Type != v.Type {
+		return false
+	}
+
+	return q.Stable == v.IsStable()
+}
+
+/*
+Check if an OS kind refers to stable images
+  - @param kind Kind of OS to test (e.g. dev, staging or stable)
+  - @returns false for dev and staging, true otherwise
+*/
+func IsStableOSKind(kind string) bool {
+	switch strings.ToLower(kind) {
+	case "dev", "staging":
+		return false
+	}
+
+	return true
+}
+
+/*
+List ManagedOSVersions
+  - @param ns Namespace
+  - @param q Query to filter the ManagedOSVersions
+  - @returns The ManagedOSVersions sorted by version (lowest first) or an error
+*/
+func ListManagedOSVersions(ns string, q OSVersionQuery) ([]ManagedOSVersion, error) {
+	out, err := kubectl.RunWithoutErr("get", "ManagedOSVersion",
+		"--namespace", ns,
+		"-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var list managedOSVersionList
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, err
+	}
+
+	var versions []ManagedOSVersion
+	for _, item := range list.Items {
+		v := ManagedOSVersion{
+			Name:         item.Metadata.Name,
+			Channel:      item.Metadata.Labels[channelLabel],
+			DisplayName:  item.Spec.Metadata.DisplayName,
+			Type:         item.Spec.Type,
+			Version:      item.Spec.Version,
+			UpgradeImage: item.Spec.Metadata.UpgradeImage,
+			URI:          item.Spec.Metadata.URI,
+		}
+
+		// Older operators do not set the label, use the owner instead
+		if v.Channel == "" {
+			for _, o := range item.Metadata.OwnerReferences {
+				if o.Kind == "ManagedOSVersionChannel" {
+					v.Channel = o.Name
+				}
+			}
+		}
+
+		// Type could be empty in some channels, ISO images are suffixed with '-iso'
+		if v.Type == "" {
+			v.Type = OSVersionContainer
+			if strings.HasSuffix(v.Name, "-iso") {
+				v.Type = OSVersionISO
+			}
+		}
+
+		if v.Matches(q) {
+			versions = append(versions, v)
+		}
+	}
+
+	slices.SortStableFunc(versions, func(a, b ManagedOSVersion) int {
+		if c := upgrade.CompareVersions(a.Version, b.Version); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return versions, nil
+}
+
+/*
+Get the highest ManagedOSVersion
+  - @param ns Namespace
+  - @param q Query to filter the ManagedOSVersions
+  - @returns The ManagedOSVersion with the highest version or an error if none is found
+*/
+func GetLatestManagedOSVersion(ns string, q OSVersionQuery) (ManagedOSVersion, error) {
+	versions, err := ListManagedOSVersions(ns, q)
+	if err != nil {
+		return ManagedOSVersion{}, err
+	}
+
+	if len(versions) == 0 {
+		return ManagedOSVersion{}, fmt.Errorf("no ManagedOSVersion found for %+v", q)
+	}
+
+	return versions[len(versions)-1], nil
+}
+
+/*
+Get last synchronization time of a ManagedOSVersionChannel
+  - @param ns Namespace
+  - @param channel Name of the ManagedOSVersionChannel
+  - @returns The status.lastSyncedTime value (could be empty) or an error
+*/
+func GetOSVersionChannelLastSync(ns, channel string) (string, error) {
+	return kubectl.RunWithoutErr("get", "ManagedOSVersionChannel",
+		"--namespace", ns, channel,
+		"-o", "jsonpath={.status.lastSyncedTime}")
+}
+
+/*
+Check if a ManagedOSVersionChannel has been synced since a previous synchronization
+  - @param ns Namespace
+  - @param channel Name of the ManagedOSVersionChannel
+  - @param lastSync Previous status.lastSyncedTime value
+  - @returns true if synced again and not reported as failed
+*/
+func isOSVersionChannelSynced(ns, channel, lastSync string) bool {
+	value, _ := GetOSVersionChannelLastSync(ns, channel)
+	if value == "" || value == lastSync {
+		return false
+	}
+
+	// Older operators do not set the Ready condition
+	ready, _ := kubectl.RunWithoutErr("get", "ManagedOSVersionChannel",
+		"--namespace", ns, channel,
+		"-o", "jsonpath={.status.conditions[?(@.type==\"Ready\")].status}")
+
+	return ready != "False"
+}
+
+/*
+Wait for a ManagedOSVersionChannel synchronization, forcing it if needed
+  - @param ns Namespace
+  - @param channel Name of the ManagedOSVersionChannel
+  - @param timeout Maximum time to wait for the synchronization
+  - @returns Nothing or an error
+*/
+func SyncOSVersionChannel(ns, channel string, timeout time.Duration) (rerr error) {
+	lastSync, err := GetOSVersionChannelLastSync(ns, channel)
+	if err != nil {
+		return err
+	}
+
+	// Get current syncInterval
+	syncValue, err := kubectl.RunWithoutErr("get", "ManagedOSVersionChannel",
+		"--namespace", ns, channel,
+		"-o", "jsonpath={.spec.syncInterval}")
+	if err != nil {
+		return err
+	}
+
+	// NOTE: the operator has no documented way to trigger a synchronization (no annotation,
+	// no sub-resource), a channel is only synced again once syncInterval is elapsed.
+	// If this happens before the timeout, simply wait for it on the channel status.
+	if interval, err := time.ParseDuration(syncValue); err == nil && interval <= timeout {
+		return helpers.PollImmediate(10*time.Second, timeout, func() (bool, error) {
+			return isOSVersionChannelSynced(ns, channel, lastSync), nil
+		})
+	}
+
+	// Fallback: reduce syncInterval to force an update and restore it at the end
+	patch := func(value string) error {
+		_, err := kubectl.RunWithoutErr("patch", "ManagedOSVersionChannel",
+			"--namespace", ns, channel,
+			"--type", "merge",
+			"--patch", "{\"spec\":{\"syncInterval\":\""+value+"\"}}")
+		return err
+	}
+	if err := patch(forcedSyncInterval); err != nil {
+		return err
+	}
+	if syncValue != "" {
+		defer func() {
+			if err := patch(syncValue); err != nil && rerr == nil {
+				rerr = err
+			}
+		}()
+	}
+
+	// Wait for a new synchronization
+	return helpers.PollImmediate(10*time.Second, timeout, func() (bool, error) {
+		return isOSVersionChannelSynced(ns, channel, lastSync), nil
+	})
+}
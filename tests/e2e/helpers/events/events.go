@@ -0,0 +1,338 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo/v2/types"
+	"github.com/rancher/elemental/tests/e2e/helpers/timing"
+)
+
+// Delay before watching again if kubectl exits, e.g. if the cluster is not yet installed
+const retryDelay = 10 * time.Second
+
+// Event is a Kubernetes Event annotated with the spec and step running when it was received
+type Event struct {
+	Received  time.Time `json:"received"`
+	Namespace string    `json:"namespace"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	// Kind/name of the involved object
+	Object  string `json:"object"`
+	Message string `json:"message"`
+	Count   int    `json:"count,omitempty"`
+	Spec    string `json:"spec,omitempty"`
+	Step    string `json:"step,omitempty"`
+}
+
+// Recorder records the Events of some namespaces into a JSON lines file
+type Recorder struct {
+	namespaces []string
+	file       *os.File
+	// Events older than this are ignored, kubectl lists them first when watching
+	since time.Time
+
+	mu sync.Mutex
+	// Events already received, by uid and resourceVersion
+	seen map[string]bool
+	// Events not yet written, waiting for the end of the spec
+	pending []Event
+	events  []Event
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+/*
+Create a new recorder
+  - @param file Name of the JSON lines file to write
+  - @param namespaces Namespaces to record the Events from
+  - @returns The recorder or an error
+*/
+func NewRecorder(file string, namespaces ...string) (*Recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		namespaces: namespaces,
+		file:       f,
+		// Event timestamps only have a precision of one second
+		since: time.Now().Truncate(time.Second),
+		seen:  map[string]bool{},
+	}, nil
+}
+
+/*
+Watch the Events in background until Stop is called
+*/
+func (r *Recorder) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		for {
+			// Existing Events are listed first, Read drops the ones older than the recorder
+			cmd := exec.CommandContext(ctx, "kubectl", "get", "events",
+				"--all-namespaces", "--watch", "--output", "json")
+			if out, err := cmd.StdoutPipe(); err == nil && cmd.Start() == nil {
+				_ = r.Read(out)
+				_ = cmd.Wait()
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+	}()
+}
+
+/*
+Stop watching and write the remaining Events
+  - @returns Nothing or an error
+*/
+func (r *Recorder) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.write(r.pending)
+	r.pending = nil
+
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+/*
+Read a stream of Events as written by kubectl
+  - @param in Stream of Events in JSON format
+  - @returns Nothing or an error, io.EOF is not an error
+*/
+func (r *Recorder) Read(in io.Reader) error {
+	// Only needed fields are defined
+	type kubeEvent struct {
+		Metadata struct {
+			Namespace         string    `json:"namespace"`
+			UID               string    `json:"uid"`
+			ResourceVersion   string    `json:"resourceVersion"`
+			CreationTimestamp time.Time `json:"creationTimestamp"`
+		} `json:"metadata"`
+		InvolvedObject struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"involvedObject"`
+		Type    string `json:"type"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+		Count   int    `json:"count"`
+		// Old (core/v1) and new (events.k8s.io) ways to date an Event, null if not set
+		LastTimestamp time.Time `json:"lastTimestamp"`
+		EventTime     time.Time `json:"eventTime"`
+	}
+
+	dec := json.NewDecoder(in)
+	for {
+		var ke kubeEvent
+		if err := dec.Decode(&ke); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if !slices.Contains(r.namespaces, ke.Metadata.Namespace) {
+			continue
+		}
+
+		// Last occurrence of the Event, as there may be several
+		last := ke.LastTimestamp
+		for _, t := range []time.Time{ke.EventTime, ke.Metadata.CreationTimestamp} {
+			if last.IsZero() {
+				last = t
+			}
+		}
+
+		r.mu.Lock()
+		// Events are listed again each time kubectl is restarted
+		key := ke.Metadata.UID + "/" + ke.Metadata.ResourceVersion
+		if !r.seen[key] && (last.IsZero() || !last.Before(r.since)) {
+			r.seen[key] = true
+			r.pending = append(r.pending, Event{
+				Received:  time.Now(),
+				Namespace: ke.Metadata.Namespace,
+				Type:      ke.Type,
+				Reason:    ke.Reason,
+				Object:    ke.InvolvedObject.Kind + "/" + ke.InvolvedObject.Name,
+				Message:   ke.Message,
+				Count:     ke.Count,
+			})
+		}
+		r.mu.Unlock()
+	}
+}
+
+/*
+Annotate and write the Events received until the end of a spec
+  - @param spec Report of the spec
+  - @returns Nothing or an error
+*/
+func (r *Recorder) Flush(spec types.SpecReport) error {
+	steps := timing.SpecSteps(spec, timing.Extractor{})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var flushed, remaining []Event
+	for _, e := range r.pending {
+		switch {
+		case e.Received.After(spec.EndTime):
+			remaining = append(remaining, e)
+			continue
+		case !e.Received.Before(spec.StartTime):
+			e.Spec = spec.FullText()
+			e.Step = stepAt(steps, e.Received)
+		}
+		flushed = append(flushed, e)
+	}
+	r.pending = remaining
+
+	return r.write(flushed)
+}
+
+/*
+Get the Events already written
+  - @returns The annotated Events, in reception order
+*/
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.events)
+}
+
+// Write events as JSON lines, must be called with the lock held
+func (r *Recorder) write(events []Event) error {
+	enc := json.NewEncoder(r.file)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		r.events = append(r.events, e)
+	}
+
+	return nil
+}
+
+// Get the innermost step running at a given time
+func stepAt(steps []timing.Step, t time.Time) string {
+	var step *timing.Step
+	for i := range steps {
+		s := &steps[i]
+		if t.Before(s.Start) || t.After(s.End) {
+			continue
+		}
+		if step == nil || s.Start.After(step.Start) {
+			step = s
+		}
+	}
+
+	if step == nil {
+		return ""
+	}
+
+	return step.Text
+}
+
+/*
+Filter Events
+  - @param events Events to filter
+  - @param keep Function returning true for the Events to keep
+  - @returns The kept Events
+*/
+func Filter(events []Event, keep func(Event) bool) []Event {
+	var kept []Event
+	for _, e := range events {
+		if keep(e) {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
+
+/*
+Summarize Events, grouped by namespace, object, reason and spec
+  - @param events Events to summarize
+  - @returns One line per group, most frequent first
+*/
+func Summary(events []Event) string {
+	type group struct {
+		key     string
+		count   int
+		message string
+	}
+
+	var groups []*group
+	byKey := map[string]*group{}
+	for _, e := range events {
+		key := fmt.Sprintf("%s %s %s %s", e.Type, e.Namespace, e.Object, e.Reason)
+		if e.Spec != "" {
+			key += " [" + e.Spec + "]"
+		}
+
+		g, found := byKey[key]
+		if !found {
+			g = &group{key: key}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.count++
+		// Keep the last message, it is the most relevant
+		g.message = e.Message
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+
+	var b strings.Builder
+	for _, g := range groups {
+		fmt.Fprintf(&b, "%dx %s: %s\n", g.count, g.key, g.message)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/events"
+)
+
+// Create an Event as written by kubectl
+func kubeEvent(ns, uid, version, eventType, reason, kind, name string) string {
+	return fmt.Sprintf(`{
+    "apiVersion": "v1",
+    "kind": "Event",
+    "metadata": {"namespace": %q, "uid": %q, "resourceVersion": %q},
+    "involvedObject": {"kind": %q, "name": %q},
+    "type": %q,
+    "reason": %q,
+    "message": "%s of %s",
+    "count": 1
+}
+`, ns, uid, version, kind, name, eventType, reason, reason, name)
+}
+
+// Date an Event as the last occurrence
+func lastSeen(event string, t time.Time) string {
+	return strings.Replace(event, `"count": 1`, fmt.Sprintf(`"count": 1, "lastTimestamp": %q`, t.UTC().Format(time.RFC3339)), 1)
+}
+
+var _ = Describe("Events tests", func() {
+	var (
+		file     string
+		recorder *events.Recorder
+	)
+
+	BeforeEach(func() {
+		var err error
+		file = filepath.Join(GinkgoT().TempDir(), "events.jsonl")
+		recorder, err = events.NewRecorder(file, "cattle-elemental-system", "fleet-default")
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	It("Record and annotate Events", func() {
+		start := time.Now()
+		stream := kubeEvent("cattle-elemental-system", "a", "1", "Normal", "Scheduled", "Pod", "seedimg") +
+			kubeEvent("kube-system", "b", "1", "Warning", "BackOff", "Pod", "coredns") +
+			kubeEvent("cattle-elemental-system", "c", "1", "Warning", "BackOff", "Pod", "seedimg") +
+			// Listed again after a restart of kubectl
+			kubeEvent("cattle-elemental-system", "a", "1", "Normal", "Scheduled", "Pod", "seedimg") +
+			// Same Event updated
+			kubeEvent("cattle-elemental-system", "c", "2", "Warning", "BackOff", "Pod", "seedimg")
+		err := recorder.Read(strings.NewReader(stream))
+		Expect(err).To(Not(HaveOccurred()))
+
+		spec := types.SpecReport{
+			LeafNodeText: "Create seed image",
+			StartTime:    start.Add(-time.Minute),
+			EndTime:      time.Now(),
+			SpecEvents: types.SpecEvents{
+				{SpecEventType: types.SpecEventByStart, Message: "Creating ISO", TimelineLocation: types.TimelineLocation{Time: start.Add(-time.Minute)}},
+				{SpecEventType: types.SpecEventByStart, Message: "Waiting for pod", TimelineLocation: types.TimelineLocation{Time: start.Add(-time.Second)}},
+			},
+		}
+		err = recorder.Flush(spec)
+		Expect(err).To(Not(HaveOccurred()))
+
+		recorded := recorder.Events()
+		Expect(recorded).To(HaveLen(3))
+		for _, e := range recorded {
+			Expect(e.Namespace).To(Equal("cattle-elemental-system"))
+			Expect(e.Object).To(Equal("Pod/seedimg"))
+			Expect(e.Spec).To(Equal("Create seed image"))
+			// Innermost step
+			Expect(e.Step).To(Equal("Waiting for pod"))
+		}
+
+		warnings := events.Filter(recorded, func(e events.Event) bool { return e.Type == "Warning" })
+		Expect(warnings).To(HaveLen(2))
+		Expect(events.Summary(warnings)).To(Equal("2x Warning cattle-elemental-system Pod/seedimg BackOff [Create seed image]: BackOff of seedimg\n"))
+
+		err = recorder.Stop()
+		Expect(err).To(Not(HaveOccurred()))
+
+		// One Event per line
+		f, err := os.Open(file)
+		Expect(err).To(Not(HaveOccurred()))
+		defer f.Close()
+
+		var lines int
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e events.Event
+			Expect(json.Unmarshal(scanner.Bytes(), &e)).To(Succeed())
+			lines++
+		}
+		Expect(lines).To(Equal(3))
+	})
+
+	It("Keep Events received after the spec", func() {
+		err := recorder.Read(strings.NewReader(kubeEvent("fleet-default", "a", "1", "Warning", "Failed", "Bundle", "fleet-agent")))
+		Expect(err).To(Not(HaveOccurred()))
+
+		// Spec ended before the Event has been received
+		past := time.Now().Add(-time.Hour)
+		err = recorder.Flush(types.SpecReport{LeafNodeText: "Old spec", StartTime: past, EndTime: past.Add(time.Minute)})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(recorder.Events()).To(BeEmpty())
+
+		// Written without spec on stop
+		err = recorder.Stop()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(recorder.Events()).To(HaveLen(1))
+		Expect(recorder.Events()[0].Spec).To(BeEmpty())
+	})
+
+	It("Ignore Events older than the recorder", func() {
+		stream := lastSeen(kubeEvent("fleet-default", "a", "1", "Warning", "Failed", "Bundle", "old"), time.Now().Add(-time.Hour)) +
+			lastSeen(kubeEvent("fleet-default", "b", "1", "Warning", "Failed", "Bundle", "new"), time.Now().Add(time.Second))
+		err := recorder.Read(strings.NewReader(stream))
+		Expect(err).To(Not(HaveOccurred()))
+
+		err = recorder.Stop()
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(recorder.Events()).To(HaveLen(1))
+		Expect(recorder.Events()[0].Object).To(Equal("Bundle/new"))
+	})
+
+	It("Fail on malformed stream", func() {
+		err := recorder.Read(strings.NewReader("{not json"))
+		Expect(err).To(HaveOccurred())
+		Expect(recorder.Stop()).To(Succeed())
+	})
+})
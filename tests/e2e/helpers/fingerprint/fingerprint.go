@@ -0,0 +1,213 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+)
+
+// Fingerprint contains the flattened fields of resources, keyed by <resource>/<namespace>/<name>#<field path>
+type Fingerprint map[string]string
+
+// DefaultResources are the resources captured by default
+var DefaultResources = []string{
+	"clusters.provisioning.cattle.io",
+	"machineinventories.elemental.cattle.io",
+	"machineinventoryselectortemplates.elemental.cattle.io",
+	"machineregistrations.elemental.cattle.io",
+	"managedosimages.elemental.cattle.io",
+	"managedosversionchannels.elemental.cattle.io",
+	"seedimages.elemental.cattle.io",
+}
+
+// DefaultIgnore contains the volatile fields, as regular expressions on the keys
+var DefaultIgnore = []*regexp.Regexp{
+	regexp.MustCompile(`#metadata\.annotations\.kubectl\.kubernetes\.io/last-applied-configuration$`),
+	regexp.MustCompile(`#metadata\.(annotations|labels)\.objectset\.rio\.cattle\.io/`),
+	regexp.MustCompile(`#metadata\.annotations\.field\.cattle\.io/`),
+	regexp.MustCompile(`^clusters\.provisioning\.cattle\.io/.*#spec\.rkeConfig\.(etcdSnapshotCreate|etcdSnapshotRestore|rotateCertificates|rotateEncryptionKeys)\.`),
+}
+
+/*
+Create a fingerprint from a list of resources
+  - @param resource Resource name with its group, e.g. machineinventories.elemental.cattle.io
+  - @param data Output of 'kubectl get -o json'
+  - @returns The flattened spec, labels and annotations of each resource or an error
+*/
+func FromList(resource string, data []byte) (Fingerprint, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Namespace   string            `json:"namespace"`
+				Labels      map[string]string `json:"labels"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Spec any `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	f := Fingerprint{}
+	for _, item := range list.Items {
+		prefix := resource + "/" + item.Metadata.Namespace + "/" + item.Metadata.Name + "#"
+
+		for k, v := range item.Metadata.Labels {
+			f[prefix+"metadata.labels."+k] = v
+		}
+		for k, v := range item.Metadata.Annotations {
+			f[prefix+"metadata.annotations."+k] = v
+		}
+		flatten(prefix+"spec", item.Spec, f)
+	}
+
+	return f, nil
+}
+
+/*
+Capture a fingerprint of the resources in the cluster
+  - @param resources Resources to capture, DefaultResources is used if empty
+  - @returns The fingerprint or an error
+*/
+func Capture(resources ...string) (Fingerprint, error) {
+	if len(resources) == 0 {
+		resources = DefaultResources
+	}
+
+	f := Fingerprint{}
+	for _, r := range resources {
+		out, err := kubectl.RunWithoutErr("get", r, "--all-namespaces", "-o", "json")
+		if err != nil {
+			return nil, err
+		}
+
+		rf, err := FromList(r, []byte(out))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r, err)
+		}
+		for k, v := range rf {
+			f[k] = v
+		}
+	}
+
+	return f, nil
+}
+
+/*
+Save a fingerprint into a file
+  - @param file File to write
+  - @returns Nothing or an error
+*/
+func (f Fingerprint) Save(file string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+/*
+Load a fingerprint from a file
+  - @param file File to read
+  - @returns The fingerprint or an error
+*/
+func Load(file string) (Fingerprint, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f := Fingerprint{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+/*
+Compare two fingerprints
+  - @param before Fingerprint before the operation
+  - @param after Fingerprint after the operation
+  - @param ignore Fields to ignore, DefaultIgnore is used if nil
+  - @returns The differences, without the ignored fields
+*/
+func Compare(before, after Fingerprint, ignore []*regexp.Regexp) misc.MapDiff {
+	if ignore == nil {
+		ignore = DefaultIgnore
+	}
+
+	filter := func(f Fingerprint) map[string]string {
+		m := map[string]string{}
+		for k, v := range f {
+			if !matchAny(ignore, k) {
+				m[k] = v
+			}
+		}
+		return m
+	}
+
+	return misc.DiffMaps(filter(before), filter(after))
+}
+
+/*
+Check if a key matches any regular expression
+  - @param list Regular expressions
+  - @param key Key to check
+  - @returns true if one expression matches
+*/
+func matchAny(list []*regexp.Regexp, key string) bool {
+	for _, r := range list {
+		if r.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Flatten a JSON value into dotted paths
+  - @param prefix Path of the value
+  - @param v Value to flatten
+  - @param out Map where to store the flattened values
+*/
+func flatten(prefix string, v any, out map[string]string) {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, e := range value {
+			flatten(prefix+"."+k, e, out)
+		}
+	case []any:
+		for i, e := range value {
+			flatten(prefix+"."+strconv.Itoa(i), e, out)
+		}
+	case nil:
+		// Empty values are not significant
+	default:
+		data, _ := json.Marshal(value)
+		out[prefix] = string(data)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fingerprint_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/fingerprint"
+)
+
+const registrations = `{"items": [{
+  "metadata": {
+    "name": "reg",
+    "namespace": "fleet-default",
+    "labels": {"pool-type": "master"},
+    "annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+    "uid": "1234"
+  },
+  "spec": {
+    "config": {"elemental": {"install": {"device-selector": [{"key": "Name", "values": ["/dev/sda"]}], "debug": true}}},
+    "machineName": null
+  },
+  "status": {"registrationToken": "abcd"}
+}]}`
+
+var _ = Describe("Fingerprint tests", func() {
+	It("Flatten resources", func() {
+		f, err := fingerprint.FromList("machineregistrations.elemental.cattle.io", []byte(registrations))
+		Expect(err).To(Not(HaveOccurred()))
+
+		prefix := "machineregistrations.elemental.cattle.io/fleet-default/reg#"
+		Expect(f).To(Equal(fingerprint.Fingerprint{
+			prefix + "metadata.labels.pool-type":                                             "master",
+			prefix + "metadata.annotations.kubectl.kubernetes.io/last-applied-configuration": "{}",
+			prefix + "spec.config.elemental.install.device-selector.0.key":                   `"Name"`,
+			prefix + "spec.config.elemental.install.device-selector.0.values.0":              `"/dev/sda"`,
+			prefix + "spec.config.elemental.install.debug":                                   "true",
+		}))
+	})
+
+	It("Compare fingerprints", func() {
+		before := fingerprint.Fingerprint{
+			"a/ns/x#spec.value": "1",
+			"a/ns/x#metadata.annotations.kubectl.kubernetes.io/last-applied-configuration": "{}",
+		}
+		after := fingerprint.Fingerprint{
+			"a/ns/x#spec.value": "2",
+			"a/ns/x#metadata.annotations.kubectl.kubernetes.io/last-applied-configuration": "{\"a\": 1}",
+		}
+
+		diff := fingerprint.Compare(before, after, nil)
+		Expect(diff.Added).To(BeEmpty())
+		Expect(diff.Removed).To(BeEmpty())
+		Expect(diff.Changed).To(HaveLen(1))
+		Expect(diff.Changed).To(HaveKey("a/ns/x#spec.value"))
+
+		Expect(fingerprint.Compare(before, before, nil).IsEmpty()).To(BeTrue())
+	})
+
+	It("Save and load fingerprints", func() {
+		f := fingerprint.Fingerprint{"a/ns/x#spec.value": "1"}
+		file := filepath.Join(GinkgoT().TempDir(), "fingerprint.json")
+
+		Expect(f.Save(file)).To(Succeed())
+		loaded, err := fingerprint.Load(file)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(loaded).To(Equal(f))
+	})
+})
@@ -0,0 +1,361 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pool describes a machine pool of a cluster
+type Pool struct {
+	Name         string `yaml:"name"`
+	Quantity     int    `yaml:"quantity"`
+	ControlPlane bool   `yaml:"controlPlane"`
+	Etcd         bool   `yaml:"etcd"`
+	Worker       bool   `yaml:"worker"`
+}
+
+// Cluster describes a downstream cluster
+type Cluster struct {
+	Name string `yaml:"name"`
+	// rke2 or k3s, deduced from the version if not set
+	Distribution string `yaml:"distribution"`
+	// Default K8s version is used if not set
+	Version string `yaml:"version"`
+	// Labels added to the selectors of all pools
+	Labels map[string]string `yaml:"labels"`
+	Pools  []Pool            `yaml:"pools"`
+	// Used by the weighted placement strategy, 1 if not set
+	Weight int `yaml:"weight"`
+	// Used by the hardware placement strategy, the multi-cluster test
+	// creates the VMs of the cluster with this number of CPU cores
+	HardwareClass string `yaml:"hardwareClass"`
+}
+
+// Layout describes all the clusters to create
+type Layout struct {
+	Placement Placement `yaml:"placement"`
+	Clusters  []Cluster `yaml:"clusters"`
+}
+
+/*
+Get the distribution of a K8s version
+  - @param version K8s version, e.g. v1.30.5+rke2r1
+  - @returns rke2, k3s or empty if unknown
+*/
+func DistributionOf(version string) string {
+	switch {
+	case strings.Contains(version, "+rke2"):
+		return "rke2"
+	case strings.Contains(version, "+k3s"):
+		return "k3s"
+	}
+
+	return ""
+}
+
+/*
+Create a layout of identical clusters
+  - @param number Number of clusters
+  - @param prefix Prefix of the cluster names
+  - @param version K8s version of all clusters
+  - @returns The layout, each cluster having 3 nodes with all roles
+*/
+func Default(number int, prefix, version string) Layout {
+	l := Layout{Placement: Placement{Strategy: RoundRobin}}
+	for i := 1; i <= number; i++ {
+		l.Clusters = append(l.Clusters, Cluster{
+			Name:         fmt.Sprintf("%s-%d", prefix, i),
+			Distribution: DistributionOf(version),
+			Version:      version,
+			Weight:       1,
+			Pools: []Pool{
+				{Name: "master", Quantity: 3, ControlPlane: true, Etcd: true, Worker: true},
+			},
+		})
+	}
+
+	return l
+}
+
+/*
+Parse a layout
+  - @param data Layout in YAML format
+  - @param defaultVersion K8s version to use if not set in a cluster
+  - @returns The validated layout or an error
+*/
+func Parse(data []byte, defaultVersion string) (Layout, error) {
+	var l Layout
+	if err := yaml.Unmarshal(data, &l); err != nil {
+		return Layout{}, err
+	}
+
+	if l.Placement.Strategy == "" {
+		l.Placement.Strategy = RoundRobin
+	}
+
+	for i := range l.Clusters {
+		c := &l.Clusters[i]
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+		if c.Version == "" {
+			c.Version = defaultVersion
+		}
+		if c.Distribution == "" {
+			c.Distribution = DistributionOf(c.Version)
+		}
+	}
+
+	if err := l.Validate(); err != nil {
+		return Layout{}, err
+	}
+
+	return l, nil
+}
+
+/*
+Load a layout from a file
+  - @param file Layout file in YAML format
+  - @param defaultVersion K8s version to use if not set in a cluster
+  - @returns The validated layout or an error
+*/
+func Load(file, defaultVersion string) (Layout, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Layout{}, err
+	}
+
+	return Parse(data, defaultVersion)
+}
+
+/*
+Validate a layout
+  - @returns Nothing or an error describing all the issues found
+*/
+func (l Layout) Validate() error {
+	var errs []error
+
+	if len(l.Clusters) == 0 {
+		errs = append(errs, errors.New("no cluster defined"))
+	}
+
+	switch l.Placement.Strategy {
+	case RoundRobin, Weighted:
+	case Hardware:
+		if l.Placement.HardwareLabel == "" {
+			errs = append(errs, errors.New("hardware placement needs a hardwareLabel"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown placement strategy %q", l.Placement.Strategy))
+	}
+
+	clusters := map[string]bool{}
+	for _, c := range l.Clusters {
+		if c.Name == "" {
+			errs = append(errs, errors.New("cluster without name"))
+		}
+		if clusters[c.Name] {
+			errs = append(errs, fmt.Errorf("cluster %s: defined more than once", c.Name))
+		}
+		clusters[c.Name] = true
+
+		if c.Distribution != "rke2" && c.Distribution != "k3s" {
+			errs = append(errs, fmt.Errorf("cluster %s: unknown distribution %q", c.Name, c.Distribution))
+		} else if DistributionOf(c.Version) != c.Distribution {
+			errs = append(errs, fmt.Errorf("cluster %s: version %q is not a %s version", c.Name, c.Version, c.Distribution))
+		}
+
+		if len(c.Pools) == 0 {
+			errs = append(errs, fmt.Errorf("cluster %s: no pool defined", c.Name))
+		}
+
+		if l.Placement.Strategy == Weighted && c.Weight <= 0 {
+			errs = append(errs, fmt.Errorf("cluster %s: weight should be positive", c.Name))
+		}
+		if l.Placement.Strategy == Hardware && c.HardwareClass == "" {
+			errs = append(errs, fmt.Errorf("cluster %s: no hardwareClass defined", c.Name))
+		}
+
+		var controlPlane, etcd, worker bool
+		pools := map[string]bool{}
+		for _, p := range c.Pools {
+			if p.Name == "" || pools[p.Name] {
+				errs = append(errs, fmt.Errorf("cluster %s: pool name %q is empty or not unique", c.Name, p.Name))
+			}
+			pools[p.Name] = true
+
+			if p.Quantity <= 0 {
+				errs = append(errs, fmt.Errorf("cluster %s: pool %s should have at least one node", c.Name, p.Name))
+			}
+			if !p.ControlPlane && !p.Etcd && !p.Worker {
+				errs = append(errs, fmt.Errorf("cluster %s: pool %s has no role", c.Name, p.Name))
+			}
+
+			controlPlane = controlPlane || p.ControlPlane
+			etcd = etcd || p.Etcd
+			worker = worker || p.Worker
+		}
+		if len(c.Pools) > 0 && (!controlPlane || !etcd || !worker) {
+			errs = append(errs, fmt.Errorf("cluster %s: controlPlane, etcd and worker roles are all needed", c.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Get the number of nodes of a layout
+  - @returns Number of nodes in all clusters
+*/
+func (l Layout) Nodes() int {
+	n := 0
+	for _, c := range l.Clusters {
+		n += c.Nodes()
+	}
+
+	return n
+}
+
+/*
+Get the number of nodes of a cluster
+  - @returns Number of nodes in all pools
+*/
+func (c Cluster) Nodes() int {
+	n := 0
+	for _, p := range c.Pools {
+		n += p.Quantity
+	}
+
+	return n
+}
+
+/*
+Get the name of the MachineInventorySelectorTemplate of a pool
+  - @param p Pool of the cluster
+  - @returns Name of the selector template
+*/
+func (c Cluster) SelectorName(p Pool) string {
+	return "selector-" + p.Name + "-" + c.Name
+}
+
+/*
+Get the labels a MachineInventory needs to be part of a pool
+  - @param p Pool of the cluster
+  - @returns Labels of the cluster with the cluster and pool names
+*/
+func (c Cluster) SelectorLabels(p Pool) map[string]string {
+	labels := map[string]string{}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	labels["clusterName"] = c.Name
+	labels["poolName"] = p.Name
+
+	return labels
+}
+
+/*
+Render the machine pools of a cluster
+  - @param indent Number of spaces to add at the beginning of each line
+  - @returns The machinePools list in YAML format or an error
+*/
+func (c Cluster) RenderPools(indent int) (string, error) {
+	type configRef struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Name       string `yaml:"name"`
+	}
+
+	type machinePool struct {
+		ControlPlaneRole     bool      `yaml:"controlPlaneRole"`
+		DrainBeforeDelete    bool      `yaml:"drainBeforeDelete"`
+		EtcdRole             bool      `yaml:"etcdRole"`
+		MachineConfigRef     configRef `yaml:"machineConfigRef"`
+		Name                 string    `yaml:"name"`
+		Quantity             int       `yaml:"quantity"`
+		UnhealthyNodeTimeout string    `yaml:"unhealthyNodeTimeout"`
+		WorkerRole           bool      `yaml:"workerRole"`
+	}
+
+	var pools []machinePool
+	for _, p := range c.Pools {
+		pools = append(pools, machinePool{
+			ControlPlaneRole:  p.ControlPlane,
+			DrainBeforeDelete: true,
+			EtcdRole:          p.Etcd,
+			MachineConfigRef: configRef{
+				APIVersion: "elemental.cattle.io/v1beta1",
+				Kind:       "MachineInventorySelectorTemplate",
+				Name:       c.SelectorName(p),
+			},
+			Name:                 "pool-" + p.Name + "-" + c.Name,
+			Quantity:             p.Quantity,
+			UnhealthyNodeTimeout: "0s",
+			WorkerRole:           p.Worker,
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(pools); err != nil {
+		return "", err
+	}
+
+	return indentLines(buf.String(), indent), nil
+}
+
+/*
+Render labels as a YAML map
+  - @param labels Labels to render
+  - @param indent Number of spaces to add at the beginning of each line
+  - @returns The labels sorted by key in YAML format
+*/
+func RenderLabels(labels map[string]string, indent int) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %q", k, labels[k]))
+	}
+
+	return indentLines(strings.Join(lines, "\n"), indent)
+}
+
+// Add spaces at the beginning of each non-empty line
+func indentLines(s string, indent int) string {
+	prefix := strings.Repeat(" ", indent)
+
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layout_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/layout"
+	"gopkg.in/yaml.v3"
+)
+
+const mixedLayout = `
+clusters:
+  - name: rke2-stable
+    labels:
+      location: europe
+    pools:
+      - name: master
+        quantity: 3
+        controlPlane: true
+        etcd: true
+      - name: worker
+        quantity: 2
+        worker: true
+  - name: k3s-next
+    version: v1.31.1+k3s1
+    pools:
+      - name: all
+        quantity: 1
+        controlPlane: true
+        etcd: true
+        worker: true
+`
+
+var _ = Describe("Layout tests", func() {
+	It("Create a default layout", func() {
+		l := layout.Default(2, "cluster-k3s", "v1.30.5+k3s1")
+		Expect(l.Validate()).To(Succeed())
+		Expect(l.Clusters).To(HaveLen(2))
+		Expect(l.Clusters[1].Name).To(Equal("cluster-k3s-2"))
+		Expect(l.Clusters[1].Distribution).To(Equal("k3s"))
+		Expect(l.Nodes()).To(Equal(6))
+	})
+
+	It("Parse a mixed layout", func() {
+		l, err := layout.Parse([]byte(mixedLayout), "v1.30.5+rke2r1")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(l.Nodes()).To(Equal(6))
+
+		rke2 := l.Clusters[0]
+		Expect(rke2.Distribution).To(Equal("rke2"))
+		Expect(rke2.Version).To(Equal("v1.30.5+rke2r1"))
+		Expect(rke2.SelectorName(rke2.Pools[1])).To(Equal("selector-worker-rke2-stable"))
+		Expect(rke2.SelectorLabels(rke2.Pools[1])).To(Equal(map[string]string{
+			"location":    "europe",
+			"clusterName": "rke2-stable",
+			"poolName":    "worker",
+		}))
+		// Cluster labels should not be modified
+		Expect(rke2.Labels).To(HaveLen(1))
+
+		k3s := l.Clusters[1]
+		Expect(k3s.Distribution).To(Equal("k3s"))
+		Expect(k3s.Version).To(Equal("v1.31.1+k3s1"))
+	})
+
+	DescribeTable("Reject invalid layouts",
+		func(data string) {
+			_, err := layout.Parse([]byte(data), "v1.30.5+rke2r1")
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no cluster", "clusters: []"),
+		Entry("distribution mismatch", `
+clusters:
+  - name: c1
+    distribution: k3s
+    pools: [{name: all, quantity: 1, controlPlane: true, etcd: true, worker: true}]
+`),
+		Entry("duplicated cluster", `
+clusters:
+  - name: c1
+    pools: [{name: all, quantity: 1, controlPlane: true, etcd: true, worker: true}]
+  - name: c1
+    pools: [{name: all, quantity: 1, controlPlane: true, etcd: true, worker: true}]
+`),
+		Entry("missing etcd role", `
+clusters:
+  - name: c1
+    pools: [{name: all, quantity: 1, controlPlane: true, worker: true}]
+`),
+		Entry("empty pool", `
+clusters:
+  - name: c1
+    pools: [{name: all, quantity: 0, controlPlane: true, etcd: true, worker: true}]
+`),
+	)
+
+	It("Render machine pools", func() {
+		l := layout.Default(1, "cluster", "v1.30.5+rke2r1")
+
+		out, err := l.Clusters[0].RenderPools(6)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(out).To(HavePrefix("      - controlPlaneRole: true\n"))
+		Expect(out).To(ContainSubstring("\n          name: selector-master-cluster-1\n"))
+
+		// Output should be valid YAML
+		var pools []map[string]interface{}
+		Expect(yaml.Unmarshal([]byte(out), &pools)).To(Succeed())
+		Expect(pools).To(HaveLen(1))
+		Expect(pools[0]["name"]).To(Equal("pool-master-cluster-1"))
+		Expect(pools[0]["quantity"]).To(Equal(3))
+	})
+
+	It("Render labels", func() {
+		out := layout.RenderLabels(map[string]string{"poolName": "worker", "clusterName": "c1"}, 4)
+		Expect(out).To(Equal("    clusterName: \"c1\"\n    poolName: \"worker\""))
+	})
+})
+
+var _ = Describe("Layout example", func() {
+	It("Load the example layout", func() {
+		l, err := layout.Load("../../../assets/multi-cluster-layout.yaml", "v1.30.5+rke2r1")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(l.Placement.Strategy).To(Equal(layout.Weighted))
+		Expect(l.Clusters).To(HaveLen(2))
+		Expect(l.Clusters[1].Distribution).To(Equal("k3s"))
+	})
+
+	It("Load the hardware example layout", func() {
+		l, err := layout.Load("../../../assets/multi-cluster-layout-hardware.yaml", "v1.30.5+rke2r1")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(l.Placement.Strategy).To(Equal(layout.Hardware))
+		Expect(l.Placement.HardwareLabel).To(Equal("elemental.cattle.io/CpuTotalCores"))
+		Expect(l.HardwareClasses()).To(Equal([]string{"4", "2", "4"}))
+	})
+})
@@ -0,0 +1,194 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layout
+
+import (
+	"fmt"
+)
+
+// Placement strategies
+const (
+	// Nodes are assigned to each cluster in turn
+	RoundRobin = "round-robin"
+	// Nodes are assigned in proportion of the cluster weights
+	Weighted = "weighted"
+	// Nodes are assigned to the clusters of their hardware class
+	Hardware = "hardware"
+)
+
+// Placement describes how nodes are assigned to clusters
+type Placement struct {
+	Strategy string `yaml:"strategy"`
+	// MachineInventory label giving the hardware class of a node
+	HardwareLabel string `yaml:"hardwareLabel"`
+}
+
+// Node is a node to place
+type Node struct {
+	Name string
+	// Hardware class, only used by the hardware strategy
+	Class string
+}
+
+// Assignment is the cluster and pool where a node should be placed
+type Assignment struct {
+	Node    string
+	Cluster string
+	Pool    string
+}
+
+// Capacity left in each pool of a cluster
+type capacity struct {
+	cluster Cluster
+	left    []int
+}
+
+// Return the first pool with capacity left, or -1 if the cluster is full
+func (c *capacity) next() int {
+	for i, n := range c.left {
+		if n > 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+Assign nodes to the clusters and pools of the layout
+  - @param nodes Nodes to place, there should be as many nodes as in the layout
+  - @returns The assignment of each node (in the same order) or an error
+*/
+func (l Layout) Place(nodes []Node) ([]Assignment, error) {
+	if len(nodes) != l.Nodes() {
+		return nil, fmt.Errorf("layout needs %d node(s), got %d", l.Nodes(), len(nodes))
+	}
+
+	capacities := make([]*capacity, len(l.Clusters))
+	for i, c := range l.Clusters {
+		capacities[i] = &capacity{cluster: c}
+		for _, p := range c.Pools {
+			capacities[i].left = append(capacities[i].left, p.Quantity)
+		}
+	}
+
+	// Clusters that can still receive the node
+	eligible := func(n Node) []int {
+		var idx []int
+		for i, c := range capacities {
+			if c.next() < 0 {
+				continue
+			}
+			if l.Placement.Strategy == Hardware && c.cluster.HardwareClass != n.Class {
+				continue
+			}
+			idx = append(idx, i)
+		}
+		return idx
+	}
+
+	var (
+		assignments []Assignment
+		last        = -1
+		current     = make([]int, len(l.Clusters))
+	)
+	for _, n := range nodes {
+		candidates := eligible(n)
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no cluster left for node %s (class %q)", n.Name, n.Class)
+		}
+
+		var chosen int
+		switch l.Placement.Strategy {
+		case Weighted:
+			// Smooth weighted round-robin
+			total := 0
+			chosen = candidates[0]
+			for _, i := range candidates {
+				current[i] += capacities[i].cluster.Weight
+				total += capacities[i].cluster.Weight
+				if current[i] > current[chosen] {
+					chosen = i
+				}
+			}
+			current[chosen] -= total
+		default:
+			// Next cluster after the last one used
+			chosen = candidates[0]
+			for _, i := range candidates {
+				if i > last {
+					chosen = i
+					break
+				}
+			}
+			last = chosen
+		}
+
+		c := capacities[chosen]
+		pool := c.next()
+		c.left[pool]--
+
+		assignments = append(assignments, Assignment{
+			Node:    n.Name,
+			Cluster: c.cluster.Name,
+			Pool:    c.cluster.Pools[pool].Name,
+		})
+	}
+
+	return assignments, nil
+}
+
+/*
+Get the hardware classes of the nodes needed by the layout
+  - @returns One class per node, clusters interleaved so that nodes are not created in placement order
+*/
+func (l Layout) HardwareClasses() []string {
+	left := make([]int, len(l.Clusters))
+	for i, c := range l.Clusters {
+		left[i] = c.Nodes()
+	}
+
+	var classes []string
+	for len(classes) < l.Nodes() {
+		for i, c := range l.Clusters {
+			if left[i] > 0 {
+				classes = append(classes, c.HardwareClass)
+				left[i]--
+			}
+		}
+	}
+
+	return classes
+}
+
+/*
+Get the cluster of an assignment
+  - @param a Assignment of a node
+  - @returns The cluster and pool, or false if not in the layout
+*/
+func (l Layout) Lookup(a Assignment) (Cluster, Pool, bool) {
+	for _, c := range l.Clusters {
+		if c.Name != a.Cluster {
+			continue
+		}
+		for _, p := range c.Pools {
+			if p.Name == a.Pool {
+				return c, p, true
+			}
+		}
+	}
+
+	return Cluster{}, Pool{}, false
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package layout_test
+
+import (
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/layout"
+)
+
+// Create nodes with hardware classes
+func nodes(classes ...string) []layout.Node {
+	var n []layout.Node
+	for i, c := range classes {
+		n = append(n, layout.Node{Name: "node-" + strconv.Itoa(i+1), Class: c})
+	}
+	return n
+}
+
+// Get the clusters of the assignments
+func clustersOf(assignments []layout.Assignment) []string {
+	var c []string
+	for _, a := range assignments {
+		c = append(c, a.Cluster)
+	}
+	return c
+}
+
+const placementLayout = `
+placement:
+  strategy: %s
+  hardwareLabel: hardware-class
+clusters:
+  - name: a
+    weight: 2
+    hardwareClass: large
+    pools:
+      - {name: master, quantity: 1, controlPlane: true, etcd: true}
+      - {name: worker, quantity: 3, worker: true}
+  - name: b
+    hardwareClass: small
+    pools:
+      - {name: all, quantity: 2, controlPlane: true, etcd: true, worker: true}
+`
+
+var _ = Describe("Placement tests", func() {
+	parse := func(strategy string) layout.Layout {
+		l, err := layout.Parse([]byte(fmt.Sprintf(placementLayout, strategy)), "v1.30.5+rke2r1")
+		Expect(err).To(Not(HaveOccurred()))
+		return l
+	}
+
+	DescribeTable("Place nodes",
+		func(strategy string, n []layout.Node, expected []string) {
+			l := parse(strategy)
+
+			assignments, err := l.Place(n)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(clustersOf(assignments)).To(Equal(expected))
+
+			// Pools are filled in order
+			var pools []string
+			for _, a := range assignments {
+				_, _, found := l.Lookup(a)
+				Expect(found).To(BeTrue())
+				if a.Cluster == "a" {
+					pools = append(pools, a.Pool)
+				}
+			}
+			Expect(pools).To(Equal([]string{"master", "worker", "worker", "worker"}))
+		},
+		Entry("round-robin", layout.RoundRobin, nodes("", "", "", "", "", ""),
+			[]string{"a", "b", "a", "b", "a", "a"}),
+		Entry("weighted", layout.Weighted, nodes("", "", "", "", "", ""),
+			[]string{"a", "b", "a", "a", "b", "a"}),
+		Entry("hardware", layout.Hardware, nodes("small", "large", "large", "small", "large", "large"),
+			[]string{"b", "a", "a", "b", "a", "a"}),
+	)
+
+	It("Get the hardware classes of the nodes", func() {
+		l := parse(layout.Hardware)
+
+		classes := l.HardwareClasses()
+		Expect(classes).To(Equal([]string{"large", "small", "large", "small", "large", "large"}))
+
+		// Nodes with these classes can be placed
+		_, err := l.Place(nodes(classes...))
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	It("Reject impossible placements", func() {
+		_, err := parse(layout.RoundRobin).Place(nodes("", ""))
+		Expect(err).To(HaveOccurred())
+
+		_, err = parse(layout.Hardware).Place(nodes("small", "small", "small", "large", "large", "large"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Reject invalid placement configuration", func() {
+		_, err := layout.Parse([]byte(fmt.Sprintf(placementLayout, "random")), "v1.30.5+rke2r1")
+		Expect(err).To(HaveOccurred())
+
+		_, err = layout.Parse([]byte(`
+placement:
+  strategy: hardware
+clusters:
+  - name: c1
+    pools: [{name: all, quantity: 1, controlPlane: true, etcd: true, worker: true}]
+`), "v1.30.5+rke2r1")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change defines the old and new values of a modified key
+type Change struct {
+	Before string
+	After  string
+}
+
+// MapDiff contains the differences between two maps
+type MapDiff struct {
+	Added   map[string]string
+	Removed map[string]string
+	Changed map[string]Change
+}
+
+// DiffExpectation defines which keys must or must not change
+type DiffExpectation struct {
+	// Keys that must be added or modified
+	MustChange []string
+	// Keys that must not be added, removed or modified
+	MustStay []string
+}
+
+/*
+Compare two maps
+  - @param before Map before the operation
+  - @param after Map after the operation
+  - @returns The added, removed and changed keys
+*/
+func DiffMaps(before, after map[string]string) MapDiff {
+	diff := MapDiff{
+		Added:   map[string]string{},
+		Removed: map[string]string{},
+		Changed: map[string]Change{},
+	}
+
+	for k, v := range before {
+		a, found := after[k]
+		switch {
+		case !found:
+			diff.Removed[k] = v
+		case a != v:
+			diff.Changed[k] = Change{Before: v, After: a}
+		}
+	}
+
+	for k, v := range after {
+		if _, found := before[k]; !found {
+			diff.Added[k] = v
+		}
+	}
+
+	return diff
+}
+
+/*
+Compare two lists, e.g. of files
+  - @param before List before the operation
+  - @param after List after the operation
+  - @returns The added and removed elements, with empty values
+*/
+func DiffLists(before, after []string) MapDiff {
+	toMap := func(list []string) map[string]string {
+		m := make(map[string]string, len(list))
+		for _, e := range list {
+			m[e] = ""
+		}
+		return m
+	}
+
+	return DiffMaps(toMap(before), toMap(after))
+}
+
+/*
+Check if there is no difference
+  - @returns true if both maps are equal
+*/
+func (d MapDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+/*
+Check if a key has been modified in any way
+  - @param key Key to check
+  - @returns true if the key has been added, removed or changed
+*/
+func (d MapDiff) Modified(key string) bool {
+	_, added := d.Added[key]
+	_, removed := d.Removed[key]
+	_, changed := d.Changed[key]
+
+	return added || removed || changed
+}
+
+/*
+Human readable version of the differences
+  - @returns One line per modified key, sorted by key
+*/
+func (d MapDiff) String() string {
+	var lines []string
+
+	for _, k := range sortedKeys(d.Added) {
+		lines = append(lines, fmt.Sprintf("+ %s: %s", k, d.Added[k]))
+	}
+	for _, k := range sortedKeys(d.Removed) {
+		lines = append(lines, fmt.Sprintf("- %s: %s", k, d.Removed[k]))
+	}
+	for _, k := range sortedKeys(d.Changed) {
+		lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", k, d.Changed[k].Before, d.Changed[k].After))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/*
+Check the differences against an expectation
+  - @param e Expected changes
+  - @returns Nothing or an error listing all the unexpected changes
+*/
+func (d MapDiff) Check(e DiffExpectation) error {
+	var errs []error
+
+	for _, k := range e.MustChange {
+		_, added := d.Added[k]
+		_, changed := d.Changed[k]
+		if !added && !changed {
+			errs = append(errs, fmt.Errorf("key %q has not been updated", k))
+		}
+	}
+
+	for _, k := range e.MustStay {
+		if d.Modified(k) {
+			errs = append(errs, fmt.Errorf("key %q should not have been modified", k))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Get the sorted keys of a map
+  - @param m Map to use
+  - @returns The sorted list of keys
+*/
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
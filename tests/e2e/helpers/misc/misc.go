@@ -16,6 +16,7 @@ package misc
 
 import (
 	"math/rand"
+	"strings"
 	"time"
 )
 
@@ -58,3 +59,24 @@ func WaitNodesBoot(index, vmIndex, bootedNodes, maxNodes int) int {
 	// Save the number of nodes already bootstrapped for the next round
 	return (index - vmIndex)
 }
+
+/*
+Get a string usable in file names
+  - @param s String to convert, e.g. a spec name
+  - @returns The lowercase string with only alphanumeric characters and dashes
+*/
+func Slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package misc_test
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+)
+
+var _ = Describe("Misc tests", func() {
+	It("Store data per node", func() {
+		var (
+			store misc.NodeStore[map[string]string]
+			wg    sync.WaitGroup
+		)
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				store.Set("node-"+strconv.Itoa(i), map[string]string{"index": strconv.Itoa(i)})
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 20; i++ {
+			value, found := store.Get("node-" + strconv.Itoa(i))
+			Expect(found).To(BeTrue())
+			Expect(value["index"]).To(Equal(strconv.Itoa(i)))
+		}
+
+		_, found := store.Get("unknown")
+		Expect(found).To(BeFalse())
+	})
+
+	It("Compare maps", func() {
+		before := map[string]string{"stable": "a", "changed": "b", "removed": "c"}
+		after := map[string]string{"stable": "a", "changed": "B", "added": "d"}
+
+		diff := misc.DiffMaps(before, after)
+		Expect(diff.IsEmpty()).To(BeFalse())
+		Expect(diff.Added).To(Equal(map[string]string{"added": "d"}))
+		Expect(diff.Removed).To(Equal(map[string]string{"removed": "c"}))
+		Expect(diff.Changed).To(Equal(map[string]misc.Change{"changed": {Before: "b", After: "B"}}))
+		Expect(diff.String()).To(Equal("+ added: d\n- removed: c\n~ changed: b -> B"))
+
+		Expect(misc.DiffMaps(before, before).IsEmpty()).To(BeTrue())
+	})
+
+	It("Compare lists", func() {
+		diff := misc.DiffLists([]string{"grubenv", "90_custom.yaml"}, []string{"grubenv", "marker"})
+		Expect(diff.Added).To(Equal(map[string]string{"marker": ""}))
+		Expect(diff.Removed).To(Equal(map[string]string{"90_custom.yaml": ""}))
+		Expect(diff.Changed).To(BeEmpty())
+	})
+
+	DescribeTable("Check map differences",
+		func(e misc.DiffExpectation, valid bool) {
+			diff := misc.DiffMaps(
+				map[string]string{"stable": "a", "changed": "b", "removed": "c"},
+				map[string]string{"stable": "a", "changed": "B", "added": "d"},
+			)
+
+			err := diff.Check(e)
+			if valid {
+				Expect(err).To(Not(HaveOccurred()))
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("no expectation", misc.DiffExpectation{}, true),
+		Entry("expected changes", misc.DiffExpectation{MustChange: []string{"changed", "added"}, MustStay: []string{"stable"}}, true),
+		Entry("unchanged key", misc.DiffExpectation{MustChange: []string{"stable"}}, false),
+		Entry("removed key is not an update", misc.DiffExpectation{MustChange: []string{"removed"}}, false),
+		Entry("changed key", misc.DiffExpectation{MustStay: []string{"changed"}}, false),
+		Entry("removed key", misc.DiffExpectation{MustStay: []string{"removed"}}, false),
+	)
+
+	DescribeTable("Get slug",
+		func(s, expected string) {
+			Expect(misc.Slug(s)).To(Equal(expected))
+		},
+		Entry("label filter", "upgrade && !rollback", "upgrade-rollback"),
+		Entry("already a slug", "node-001", "node-001"),
+		Entry("empty", "", ""),
+	)
+})
+
+var _ = Describe("Systemd tests", func() {
+	It("Parse systemctl show output", func() {
+		out := `ActiveState=active
+ExecMainStartTimestamp=Mon 2025-01-06 10:00:00 UTC
+ExecMainExitTimestamp=
+Description=Elemental Register Reset
+`
+		properties := misc.ParseSystemdProperties(out)
+		Expect(properties).To(HaveLen(4))
+		Expect(properties["ActiveState"]).To(Equal("active"))
+
+		start, err := misc.ParseSystemdTimestamp(properties["ExecMainStartTimestamp"])
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(start).To(Equal(time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)))
+
+		exit, err := misc.ParseSystemdTimestamp(properties["ExecMainExitTimestamp"])
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(exit.IsZero()).To(BeTrue())
+
+		_, err = misc.ParseSystemdTimestamp("yesterday")
+		Expect(err).To(HaveOccurred())
+	})
+})
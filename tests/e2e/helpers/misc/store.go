@@ -0,0 +1,55 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package misc
+
+import (
+	"sync"
+)
+
+// NodeStore keeps data per node, it can be used from multiple goroutines
+type NodeStore[T any] struct {
+	mutex sync.Mutex
+	data  map[string]T
+}
+
+/*
+Save data for a node
+  - @param node Name of the node
+  - @param value Data to save
+  - @returns Nothing
+*/
+func (s *NodeStore[T]) Set(node string, value T) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		s.data = make(map[string]T)
+	}
+	s.data[node] = value
+}
+
+/*
+Get data of a node
+  - @param node Name of the node
+  - @returns The saved data and true if found
+*/
+func (s *NodeStore[T]) Get(node string) (T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, found := s.data[node]
+
+	return value, found
+}
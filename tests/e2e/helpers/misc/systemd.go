@@ -0,0 +1,55 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package misc
+
+import (
+	"strings"
+	"time"
+)
+
+// Timestamp format used by 'systemctl show'
+const systemdTimestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+/*
+Parse the output of 'systemctl show'
+  - @param out Output of the command, one Key=Value per line
+  - @returns The properties
+*/
+func ParseSystemdProperties(out string) map[string]string {
+	properties := map[string]string{}
+
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if found && key != "" {
+			properties[key] = value
+		}
+	}
+
+	return properties
+}
+
+/*
+Parse a systemd timestamp
+  - @param value Timestamp as displayed by 'systemctl show'
+  - @returns The time, zero time if not set ('n/a' or empty), or an error
+*/
+func ParseSystemdTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "n/a" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(systemdTimestampLayout, value)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+// Class of a path, as defined in 01_elemental-rootfs.yaml
+type Class string
+
+// Event is an operation done on the node between Write and Verify
+type Event string
+
+const (
+	// Persistent paths are bind mounted from the persistent partition
+	Persistent Class = "persistent"
+	// Ephemeral paths are on tmpfs (or overlay on tmpfs)
+	Ephemeral Class = "ephemeral"
+	// ReadOnly paths are part of the read-only root filesystem
+	ReadOnly Class = "read-only"
+
+	// Reboot of the node in active mode
+	Reboot Event = "reboot"
+	// Upgrade of the node, a reboot is done at the end
+	Upgrade Event = "upgrade"
+	// Reset of the node, with persistent and OEM partitions wiped (operator default)
+	Reset Event = "reset"
+
+	// Prefix of the marker files
+	markerPrefix = ".elemental-e2e-persistence-"
+	// Returned by the node when a marker is not found
+	missing = "missing"
+)
+
+// Marker is a path where a marker file is written
+type Marker struct {
+	Dir   string
+	Class Class
+}
+
+// Checker writes marker files on a node and verifies them after an event
+type Checker struct {
+	Markers []Marker
+	token   string
+	sums    map[string]string
+}
+
+// DefaultMarkers covers the different kinds of paths of an Elemental node
+var DefaultMarkers = []Marker{
+	{Dir: "/etc/rancher", Class: Persistent},
+	{Dir: "/etc/ssh", Class: Persistent},
+	{Dir: "/etc/systemd", Class: Persistent},
+	{Dir: "/home", Class: Persistent},
+	{Dir: "/oem", Class: Persistent},
+	{Dir: "/opt", Class: Persistent},
+	{Dir: "/root", Class: Persistent},
+	{Dir: "/usr/local", Class: Persistent},
+	{Dir: "/var/lib/elemental", Class: Persistent},
+	{Dir: "/var/lib/rancher", Class: Persistent},
+	{Dir: "/var/log", Class: Persistent},
+	{Dir: "/etc", Class: Ephemeral},
+	{Dir: "/run", Class: Ephemeral},
+	{Dir: "/srv", Class: Ephemeral},
+	{Dir: "/tmp", Class: Ephemeral},
+	{Dir: "/var", Class: Ephemeral},
+	{Dir: "/usr/lib", Class: ReadOnly},
+}
+
+/*
+Create a new persistence checker
+  - @param token Unique value written in the marker files
+  - @param markers Paths to check, DefaultMarkers is used if empty
+  - @returns The checker
+*/
+func New(token string, markers ...Marker) *Checker {
+	if len(markers) == 0 {
+		markers = DefaultMarkers
+	}
+
+	return &Checker{
+		Markers: markers,
+		token:   token,
+		sums:    map[string]string{},
+	}
+}
+
+/*
+Check if a marker should survive an event
+  - @param class Class of the marker path
+  - @param event Event done on the node
+  - @returns true if the marker should still be there after the event
+*/
+func Expected(class Class, event Event) bool {
+	// Ephemeral markers are lost at each reboot and read-only ones cannot be written
+	return class == Persistent && event != Reset
+}
+
+/*
+Get the path of the marker file
+  - @param m Marker
+  - @returns Full path of the marker file
+*/
+func (c *Checker) File(m Marker) string {
+	return path.Join(m.Dir, markerPrefix+c.token)
+}
+
+/*
+Write the marker files on a node
+  - @param cl Client (node) informations
+  - @returns Nothing or an error if a marker cannot be written (or can be written on a read-only path)
+*/
+func (c *Checker) Write(cl *tools.Client) error {
+	var errs []error
+
+	for _, m := range c.Markers {
+		f := c.File(m)
+		out, err := cl.RunSSH("mkdir -p " + m.Dir + " && echo " + c.token + " > " + f + " && sha256sum " + f)
+
+		if m.Class == ReadOnly {
+			if err == nil {
+				errs = append(errs, fmt.Errorf("%s: write should fail on read-only path", f))
+			}
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+			continue
+		}
+
+		sum, err := ParseChecksum(out)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+			continue
+		}
+		c.sums[f] = sum
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Verify the marker files on a node after an event
+  - @param cl Client (node) informations
+  - @param event Event done on the node since Write
+  - @returns Nothing or an error listing all the unexpected markers
+*/
+func (c *Checker) Verify(cl *tools.Client, event Event) error {
+	var errs []error
+
+	for _, m := range c.Markers {
+		f := c.File(m)
+		out, err := cl.RunSSH("sha256sum " + f + " 2>/dev/null || echo " + missing)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+			continue
+		}
+
+		if err := c.check(m, out, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+/*
+Remove the marker files from a node, so that they are not seen by later checks (e.g. OEM files after a reset)
+  - @param cl Client (node) informations
+  - @returns Nothing or an error
+*/
+func (c *Checker) Cleanup(cl *tools.Client) error {
+	var files []string
+	for _, m := range c.Markers {
+		// Nothing can be written on read-only paths
+		if m.Class != ReadOnly {
+			files = append(files, c.File(m))
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	_, err := cl.RunSSH("rm -f " + strings.Join(files, " "))
+	return err
+}
+
+/*
+Check a marker against the expected matrix
+  - @param m Marker
+  - @param out Output of sha256sum on the node, or 'missing'
+  - @param event Event done on the node since Write
+  - @returns Nothing or an error if the marker is not in the expected state
+*/
+func (c *Checker) check(m Marker, out string, event Event) error {
+	f := c.File(m)
+	expected := Expected(m.Class, event)
+
+	if strings.TrimSpace(out) == missing {
+		if expected {
+			return fmt.Errorf("%s: %s marker lost after %s", f, m.Class, event)
+		}
+		return nil
+	}
+
+	if !expected {
+		return fmt.Errorf("%s: %s marker still present after %s", f, m.Class, event)
+	}
+
+	sum, err := ParseChecksum(out)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f, err)
+	}
+	if sum != c.sums[f] {
+		return fmt.Errorf("%s: checksum mismatch after %s (%s != %s)", f, event, sum, c.sums[f])
+	}
+
+	return nil
+}
+
+/*
+Extract the checksum from sha256sum output
+  - @param out Output of sha256sum
+  - @returns The checksum or an error
+*/
+func ParseChecksum(out string) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 || len(fields[0]) != 64 {
+		return "", fmt.Errorf("invalid sha256sum output %q", strings.TrimSpace(out))
+	}
+
+	return fields[0], nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package persistence_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/persistence"
+)
+
+var _ = Describe("Persistence tests", func() {
+	DescribeTable("Expected matrix",
+		func(class persistence.Class, reboot, upgrade, reset bool) {
+			Expect(persistence.Expected(class, persistence.Reboot)).To(Equal(reboot))
+			Expect(persistence.Expected(class, persistence.Upgrade)).To(Equal(upgrade))
+			Expect(persistence.Expected(class, persistence.Reset)).To(Equal(reset))
+		},
+		Entry("persistent", persistence.Persistent, true, true, false),
+		Entry("ephemeral", persistence.Ephemeral, false, false, false),
+		Entry("read-only", persistence.ReadOnly, false, false, false),
+	)
+
+	It("Use default markers", func() {
+		c := persistence.New("1234")
+		Expect(c.Markers).To(Equal(persistence.DefaultMarkers))
+		Expect(c.File(persistence.Marker{Dir: "/home"})).To(Equal("/home/.elemental-e2e-persistence-1234"))
+	})
+
+	It("Parse sha256sum output", func() {
+		sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+		out, err := persistence.ParseChecksum(sum + "  /home/file\n")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(out).To(Equal(sum))
+
+		_, err = persistence.ParseChecksum("missing\n")
+		Expect(err).To(HaveOccurred())
+	})
+})
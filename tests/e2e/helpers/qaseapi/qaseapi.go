@@ -0,0 +1,288 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qaseapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	qase "go.qase.io/client"
+)
+
+// Maximum number of entities returned by the Qase API in one call
+const pageSize = 100
+
+// DefaultURL is the URL of the Qase API
+var DefaultURL = qase.NewConfiguration().BasePath
+
+// Client is a minimal Qase API client, for what the generated one does not support
+type Client struct {
+	URL     string
+	Token   string
+	Project string
+	HTTP    *http.Client
+}
+
+// Run is a Qase run, times are kept as returned by the API
+type Run struct {
+	ID          int64           `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Status      int             `json:"status"`
+	StatusText  string          `json:"status_text,omitempty"`
+	Public      bool            `json:"public"`
+	StartTime   string          `json:"start_time,omitempty"`
+	EndTime     string          `json:"end_time,omitempty"`
+	Stats       json.RawMessage `json:"stats,omitempty"`
+	Cases       []int64         `json:"cases,omitempty"`
+}
+
+// Result is the result of a case in a Qase run
+type Result struct {
+	Hash   string `json:"hash"`
+	RunID  int64  `json:"run_id"`
+	CaseID int64  `json:"case_id"`
+	Status string `json:"status"`
+}
+
+// Attachment is an uploaded file
+type Attachment struct {
+	Hash     string `json:"hash"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
+// Envelope of all the Qase API responses
+type response struct {
+	Status       bool            `json:"status"`
+	Result       json.RawMessage `json:"result"`
+	ErrorMessage string          `json:"errorMessage"`
+}
+
+// List of entities in a Qase API response
+type list[T any] struct {
+	Total    int `json:"total"`
+	Count    int `json:"count"`
+	Entities []T `json:"entities"`
+}
+
+/*
+Create a client configured with the Qase environment variables
+  - @returns The client, QASE_API_URL can override the default API URL
+*/
+func New() *Client {
+	u := os.Getenv("QASE_API_URL")
+	if u == "" {
+		u = DefaultURL
+	}
+
+	return &Client{
+		URL:     u,
+		Token:   os.Getenv("QASE_API_TOKEN"),
+		Project: os.Getenv("QASE_PROJECT_CODE"),
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// Send a request and decode the result of the response
+func (c *Client) do(method, path string, query url.Values, contentType string, body io.Reader, result any) error {
+	u := c.URL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Token", c.Token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with %s: %s", method, path, resp.Status, out)
+	}
+
+	var r response
+	if err := json.Unmarshal(out, &r); err != nil {
+		return err
+	}
+	if !r.Status {
+		return fmt.Errorf("%s %s failed: %s", method, path, r.ErrorMessage)
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
+
+// Send a JSON body
+func (c *Client) doJSON(method, path string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return c.do(method, path, nil, "application/json", bytes.NewReader(data), result)
+}
+
+// Get all the pages of a list
+func getAll[T any](c *Client, path string, query url.Values) ([]T, error) {
+	var all []T
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+
+	for offset := 0; ; offset += pageSize {
+		query.Set("offset", strconv.Itoa(offset))
+
+		var l list[T]
+		if err := c.do(http.MethodGet, path, query, "", nil, &l); err != nil {
+			return nil, err
+		}
+		all = append(all, l.Entities...)
+
+		if len(l.Entities) < pageSize || len(all) >= l.Total {
+			return all, nil
+		}
+	}
+}
+
+/*
+List the runs of the project
+  - @param status Only list runs with this status (active, complete, abort), all if empty
+  - @returns The runs or an error
+*/
+func (c *Client) ListRuns(status string) ([]Run, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("filters[status]", status)
+	}
+
+	return getAll[Run](c, "/run/"+c.Project, query)
+}
+
+/*
+Get a run
+  - @param id ID of the run
+  - @returns The run with its cases or an error
+*/
+func (c *Client) GetRun(id int64) (Run, error) {
+	var r Run
+	err := c.do(http.MethodGet, fmt.Sprintf("/run/%s/%d", c.Project, id), url.Values{"include": {"cases"}}, "", nil, &r)
+
+	return r, err
+}
+
+/*
+List the results of a run
+  - @param id ID of the run
+  - @returns The results or an error
+*/
+func (c *Client) ListResults(id int64) ([]Result, error) {
+	return getAll[Result](c, "/result/"+c.Project, url.Values{"filters[run]": {strconv.FormatInt(id, 10)}})
+}
+
+/*
+Upload a file as an attachment, the generated client does not support it
+  - @param file File to upload
+  - @returns The uploaded attachment or an error
+*/
+func (c *Client) Upload(file string) (Attachment, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filepath.Base(file))
+	if err != nil {
+		return Attachment{}, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return Attachment{}, err
+	}
+	if err := w.Close(); err != nil {
+		return Attachment{}, err
+	}
+
+	var uploaded []qase.AttachmentGet
+	if err := c.do(http.MethodPost, "/attachment/"+c.Project, nil, w.FormDataContentType(), &body, &uploaded); err != nil {
+		return Attachment{}, err
+	}
+	if len(uploaded) == 0 {
+		return Attachment{}, errors.New("no attachment returned by Qase")
+	}
+
+	return Attachment{
+		Hash:     uploaded[0].Hash,
+		Filename: uploaded[0].File,
+		URL:      uploaded[0].FullPath,
+	}, nil
+}
+
+/*
+Attach uploaded files to a result
+  - @param run ID of the run
+  - @param hash Hash of the result
+  - @param attachments Hashes of the uploaded files
+  - @returns Nothing or an error
+*/
+func (c *Client) AttachToResult(run int64, hash string, attachments []string) error {
+	return c.doJSON(http.MethodPatch, fmt.Sprintf("/result/%s/%d/%s", c.Project, run, hash),
+		qase.ResultUpdate{Attachments: attachments}, nil)
+}
+
+/*
+Update the title and the tags of a case
+  - @param id ID of the case
+  - @param title New title of the case
+  - @param tags New tags of the case
+  - @returns Nothing or an error
+*/
+func (c *Client) UpdateCase(id int64, title string, tags []string) error {
+	// Tags are not supported by the generated client
+	body := struct {
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}{title, tags}
+
+	return c.doJSON(http.MethodPatch, fmt.Sprintf("/case/%s/%d", c.Project, id), body, nil)
+}
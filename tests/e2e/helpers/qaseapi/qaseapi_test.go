@@ -0,0 +1,176 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qaseapi_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/qaseapi"
+)
+
+// Local stand-in for the Qase API
+type standIn struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]string
+	// Results created, and number of calls to fail before creating them
+	created  []string
+	failures int
+}
+
+// Write a Qase API response
+func reply(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": true, "result": result})
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Token") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"status": false, "errorMessage": "Unauthorized"}`))
+		return
+	}
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /run/ELEMENTAL":
+		// 150 runs, returned by pages
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var runs []map[string]any
+		for i := offset; i < min(offset+limit, 150); i++ {
+			runs = append(runs, map[string]any{"id": i + 1, "title": fmt.Sprintf("run %d", i+1)})
+		}
+		reply(w, map[string]any{"total": 150, "count": len(runs), "entities": runs})
+	case "GET /run/ELEMENTAL/5":
+		reply(w, map[string]any{
+			"id": 5, "title": "Nightly", "status": 1, "status_text": "passed",
+			"start_time": "2025-01-06 10:00:00", "cases": []int{9, 38},
+			"stats": map[string]any{"total": 2, "statuses": map[string]int{"passed": 2}},
+		})
+	case "GET /result/ELEMENTAL":
+		Expect(r.URL.Query().Get("filters[run]")).To(Equal("5"))
+		reply(w, map[string]any{"total": 2, "count": 2, "entities": []map[string]any{
+			{"hash": "aaa", "run_id": 5, "case_id": 9, "status": "passed"},
+			{"hash": "bbb", "run_id": 5, "case_id": 38, "status": "failed"},
+		}})
+	case "POST /attachment/ELEMENTAL":
+		file, header, err := r.FormFile("file")
+		Expect(err).To(Not(HaveOccurred()))
+		data, err := io.ReadAll(file)
+		Expect(err).To(Not(HaveOccurred()))
+		s.bodies[r.URL.Path] = string(data)
+		reply(w, []map[string]any{{"hash": "f00", "file": header.Filename, "full_path": "https://qase.example/f00"}})
+	case "POST /result/ELEMENTAL/5":
+		if s.failures > 0 {
+			s.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		Expect(err).To(Not(HaveOccurred()))
+		s.created = append(s.created, string(data))
+		reply(w, map[string]any{"case_id": 9, "hash": fmt.Sprintf("h%d", len(s.created))})
+	case "PATCH /result/ELEMENTAL/5/aaa", "PATCH /case/ELEMENTAL/9":
+		data, err := io.ReadAll(r.Body)
+		Expect(err).To(Not(HaveOccurred()))
+		s.bodies[r.URL.Path] = string(data)
+		reply(w, map[string]any{"id": 9})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"status": false, "errorMessage": "Not found"}`))
+	}
+}
+
+var _ = Describe("Qase API tests", func() {
+	var (
+		api    *standIn
+		client *qaseapi.Client
+	)
+
+	BeforeEach(func() {
+		api = &standIn{bodies: map[string]string{}}
+		server := httptest.NewServer(api)
+		DeferCleanup(server.Close)
+
+		client = &qaseapi.Client{URL: server.URL, Token: "secret", Project: "ELEMENTAL", HTTP: server.Client()}
+	})
+
+	It("List runs on several pages", func() {
+		runs, err := client.ListRuns("")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(runs).To(HaveLen(150))
+		Expect(runs[149].Title).To(Equal("run 150"))
+		Expect(api.requests).To(HaveLen(2))
+	})
+
+	It("Show a run and its results", func() {
+		run, err := client.GetRun(5)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(run.Title).To(Equal("Nightly"))
+		Expect(run.StartTime).To(Equal("2025-01-06 10:00:00"))
+		Expect(run.Cases).To(Equal([]int64{9, 38}))
+		Expect(string(run.Stats)).To(ContainSubstring(`"total":2`))
+
+		results, err := client.ListResults(5)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(results).To(Equal([]qaseapi.Result{
+			{Hash: "aaa", RunID: 5, CaseID: 9, Status: "passed"},
+			{Hash: "bbb", RunID: 5, CaseID: 38, Status: "failed"},
+		}))
+	})
+
+	It("Upload and attach a file", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "console.log")
+		Expect(os.WriteFile(file, []byte("boot ok"), 0644)).To(Succeed())
+
+		attachment, err := client.Upload(file)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(attachment).To(Equal(qaseapi.Attachment{Hash: "f00", Filename: "console.log", URL: "https://qase.example/f00"}))
+		Expect(api.bodies["/attachment/ELEMENTAL"]).To(Equal("boot ok"))
+
+		err = client.AttachToResult(5, "aaa", []string{attachment.Hash})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(api.bodies["/result/ELEMENTAL/5/aaa"]).To(MatchJSON(`{"attachments": ["f00"]}`))
+	})
+
+	It("Update a case", func() {
+		err := client.UpdateCase(9, "E2E - Bootstrapping node / Provision the node", []string{"bootstrap"})
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(api.bodies["/case/ELEMENTAL/9"]).To(MatchJSON(`{"title": "E2E - Bootstrapping node / Provision the node", "tags": ["bootstrap"]}`))
+	})
+
+	It("Report API errors", func() {
+		_, err := client.GetRun(6)
+		Expect(err).To(MatchError(ContainSubstring("404")))
+
+		client.Token = "wrong"
+		_, err = client.ListRuns("")
+		Expect(err).To(MatchError(ContainSubstring("Unauthorized")))
+	})
+})
@@ -0,0 +1,337 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qaseapi
+
+/*
+Spool format
+
+When the Qase API cannot be reached from the runner, results are written in a
+spool directory and uploaded later with "qase_cmd -upload-spool":
+
+	<spool>/results/<id>.json        one result per file, see SpooledResult
+	<spool>/attachments/<id>/<file>  files attached to the result <id>
+	<spool>/uploaded.json            what has already been uploaded, see ledger
+
+The id of a result is derived from the run, the case and the spec execution, so
+spooling the same result twice only writes it once. Results already in the
+ledger are not uploaded again, an interrupted upload can simply be restarted.
+*/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2/types"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	qase "go.qase.io/client"
+)
+
+// Sub-directories and files of the spool directory
+const (
+	SpoolResultsDir     = "results"
+	SpoolAttachmentsDir = "attachments"
+	SpoolLedger         = "uploaded.json"
+)
+
+// SpooledResult is a result waiting to be uploaded
+type SpooledResult struct {
+	ID string `json:"id"`
+	// Run ID, the one given at upload time is used if not set
+	Run     int64  `json:"run,omitempty"`
+	Case    int64  `json:"case"`
+	Spec    string `json:"spec"`
+	Status  string `json:"status"`
+	TimeMs  int64  `json:"timeMs"`
+	Comment string `json:"comment,omitempty"`
+	// Only set for failed results
+	Stacktrace string `json:"stacktrace,omitempty"`
+	// Paths of the attachments, relative to the spool directory
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// SpoolReport is the outcome of an upload of the spool directory
+type SpoolReport struct {
+	Uploaded []string          `json:"uploaded"`
+	Skipped  []string          `json:"skipped"`
+	Failed   map[string]string `json:"failed,omitempty"`
+}
+
+// Already uploaded results and attachments, by id and by path
+type ledger struct {
+	Results     map[string]string `json:"results"`
+	Attachments map[string]string `json:"attachments"`
+}
+
+/*
+Get the Qase status of a spec, as reported by qase-ginkgo
+  - @param report Report of the spec
+  - @returns The Qase status
+*/
+func Status(report types.SpecReport) string {
+	switch {
+	case report.State.Is(types.SpecStateFailureStates):
+		return "failed"
+	case report.State == types.SpecStatePassed:
+		return "passed"
+	case report.State == types.SpecStatePending:
+		return "blocked"
+	case report.State == types.SpecStateSkipped:
+		return "skipped"
+	}
+
+	return "invalid"
+}
+
+/*
+Create a result to spool from a spec report
+  - @param run ID of the Qase run, 0 if not known yet
+  - @param id ID of the Qase case
+  - @param report Report of the spec
+  - @returns The result to spool
+*/
+func NewSpooledResult(run, id int64, report types.SpecReport) SpooledResult {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d/%d/%s/%s", run, id, report.FullText(), report.StartTime.Format(time.RFC3339Nano))))
+
+	r := SpooledResult{
+		ID:     hex.EncodeToString(sum[:8]),
+		Run:    run,
+		Case:   id,
+		Spec:   report.FullText(),
+		Status: Status(report),
+		TimeMs: report.RunTime.Milliseconds(),
+	}
+	if report.Failed() {
+		r.Comment = report.Failure.Message + "\n\nFailed at: " + report.Failure.Location.String()
+		r.Stacktrace = report.Failure.Location.FullStackTrace
+	}
+
+	return r
+}
+
+/*
+Write a result and its attachments in the spool directory
+  - @param dir Spool directory
+  - @param r Result to write
+  - @param files Files to attach to the result
+  - @returns Nothing or an error
+*/
+func Spool(dir string, r SpooledResult, files ...string) error {
+	for _, file := range files {
+		rel := filepath.Join(SpoolAttachmentsDir, r.ID, filepath.Base(file))
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(rel)), 0755); err != nil {
+			return err
+		}
+		if err := tools.CopyFile(file, filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+		r.Attachments = append(r.Attachments, rel)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, SpoolResultsDir), 0755); err != nil {
+		return err
+	}
+
+	return writeJSON(filepath.Join(dir, SpoolResultsDir, r.ID+".json"), r)
+}
+
+/*
+Write the result of a spec in the spool directory
+  - @param dir Spool directory
+  - @param id ID of the Qase case, nothing is written if not set
+  - @param report Report of the spec
+  - @param files Files to attach to the result
+  - @returns Nothing or an error
+*/
+func SpoolSpec(dir string, id int64, report types.SpecReport, files ...string) error {
+	if id <= 0 {
+		return nil
+	}
+
+	// The run can be created later, when uploading
+	run, _ := strconv.ParseInt(os.Getenv("QASE_RUN_ID"), 10, 32)
+
+	return Spool(dir, NewSpooledResult(run, id, report), files...)
+}
+
+// Write a file atomically, an interrupted write does not leave a partial file
+func writeJSON(file string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// Call a function until it succeeds
+func retry(attempts int, delay time.Duration, f func() error) error {
+	var err error
+	for i := 0; i < max(attempts, 1); i++ {
+		if i > 0 {
+			time.Sleep(delay * time.Duration(i))
+		}
+		if err = f(); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+/*
+Create a result in a run
+  - @param run ID of the run
+  - @param r Result to create
+  - @returns The hash of the result or an error
+*/
+func (c *Client) CreateResult(run int64, r qase.ResultCreate) (string, error) {
+	var created struct {
+		Hash string `json:"hash"`
+	}
+	err := c.doJSON(http.MethodPost, fmt.Sprintf("/result/%s/%d", c.Project, run), r, &created)
+
+	return created.Hash, err
+}
+
+/*
+Upload the results of a spool directory
+  - @param dir Spool directory
+  - @param run ID of the run for the results spooled without one
+  - @param attempts Number of attempts for each API call
+  - @param delay Delay between attempts, multiplied by the number of attempts
+  - @returns What has been uploaded, and an error if some results failed
+*/
+func (c *Client) UploadSpool(dir string, run int64, attempts int, delay time.Duration) (SpoolReport, error) {
+	report := SpoolReport{Uploaded: []string{}, Skipped: []string{}, Failed: map[string]string{}}
+
+	l := ledger{Results: map[string]string{}, Attachments: map[string]string{}}
+	if data, err := os.ReadFile(filepath.Join(dir, SpoolLedger)); err == nil {
+		if err := json.Unmarshal(data, &l); err != nil {
+			return report, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return report, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, SpoolResultsDir, "*.json"))
+	if err != nil {
+		return report, err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		var r SpooledResult
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = json.Unmarshal(data, &r)
+		}
+		if err != nil {
+			report.Failed[filepath.Base(file)] = err.Error()
+			continue
+		}
+
+		if _, done := l.Results[r.ID]; done {
+			report.Skipped = append(report.Skipped, r.ID)
+			continue
+		}
+
+		if err := c.uploadResult(dir, r, run, attempts, delay, &l); err != nil {
+			report.Failed[r.ID] = err.Error()
+		} else {
+			report.Uploaded = append(report.Uploaded, r.ID)
+		}
+
+		// Saved after each result, in case the upload is interrupted
+		if err := writeJSON(filepath.Join(dir, SpoolLedger), l); err != nil {
+			return report, err
+		}
+	}
+
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf("%d result(s) not uploaded", len(report.Failed))
+	}
+
+	return report, nil
+}
+
+// Upload a spooled result and its attachments
+func (c *Client) uploadResult(dir string, r SpooledResult, run int64, attempts int, delay time.Duration, l *ledger) error {
+	if r.Run > 0 {
+		run = r.Run
+	}
+	if run <= 0 {
+		return errors.New("no run id")
+	}
+
+	var hashes []string
+	for _, a := range r.Attachments {
+		if strings.Contains(a, "..") {
+			return fmt.Errorf("wrong attachment path %q", a)
+		}
+
+		// Attachments are only uploaded once, even if the result failed
+		if hash, done := l.Attachments[a]; done {
+			hashes = append(hashes, hash)
+			continue
+		}
+
+		var uploaded Attachment
+		err := retry(attempts, delay, func() (err error) {
+			uploaded, err = c.Upload(filepath.Join(dir, a))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		l.Attachments[a] = uploaded.Hash
+		hashes = append(hashes, uploaded.Hash)
+	}
+
+	result := qase.ResultCreate{
+		CaseId:      r.Case,
+		Status:      r.Status,
+		TimeMs:      r.TimeMs,
+		Comment:     r.Comment,
+		Stacktrace:  r.Stacktrace,
+		Attachments: hashes,
+	}
+
+	var hash string
+	err := retry(attempts, delay, func() (err error) {
+		hash, err = c.CreateResult(run, result)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	l.Results[r.ID] = hash
+
+	return nil
+}
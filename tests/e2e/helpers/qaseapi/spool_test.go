@@ -0,0 +1,141 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qaseapi_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/qaseapi"
+)
+
+var _ = Describe("Spool tests", func() {
+	var (
+		api    *standIn
+		client *qaseapi.Client
+		dir    string
+		diag   string
+	)
+
+	failed := types.SpecReport{
+		LeafNodeText: "Upgrade node",
+		State:        types.SpecStateFailed,
+		StartTime:    time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC),
+		RunTime:      90 * time.Second,
+		Failure:      types.Failure{Message: "timeout"},
+	}
+
+	BeforeEach(func() {
+		api = &standIn{bodies: map[string]string{}}
+		server := httptest.NewServer(api)
+		DeferCleanup(server.Close)
+
+		client = &qaseapi.Client{URL: server.URL, Token: "secret", Project: "ELEMENTAL", HTTP: server.Client()}
+		dir = GinkgoT().TempDir()
+
+		diag = filepath.Join(GinkgoT().TempDir(), "diagnostics.txt")
+		Expect(os.WriteFile(diag, []byte("node-001 not ready"), 0644)).To(Succeed())
+	})
+
+	It("Spool a result", func() {
+		r := qaseapi.NewSpooledResult(0, 73, failed)
+		Expect(r.Status).To(Equal("failed"))
+		Expect(r.TimeMs).To(Equal(int64(90000)))
+		Expect(r.Comment).To(HavePrefix("timeout"))
+		// Same execution, same id
+		Expect(qaseapi.NewSpooledResult(0, 73, failed).ID).To(Equal(r.ID))
+		Expect(qaseapi.NewSpooledResult(0, 72, failed).ID).To(Not(Equal(r.ID)))
+
+		Expect(qaseapi.Spool(dir, r, diag)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(dir, qaseapi.SpoolResultsDir, r.ID+".json"))
+		Expect(err).To(Not(HaveOccurred()))
+		var spooled qaseapi.SpooledResult
+		Expect(json.Unmarshal(data, &spooled)).To(Succeed())
+		Expect(spooled.Attachments).To(Equal([]string{filepath.Join(qaseapi.SpoolAttachmentsDir, r.ID, "diagnostics.txt")}))
+
+		data, err = os.ReadFile(filepath.Join(dir, spooled.Attachments[0]))
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(Equal("node-001 not ready"))
+
+		// Nothing to spool without case
+		Expect(qaseapi.SpoolSpec(dir, -1, failed)).To(Succeed())
+		files, _ := filepath.Glob(filepath.Join(dir, qaseapi.SpoolResultsDir, "*"))
+		Expect(files).To(HaveLen(1))
+	})
+
+	It("Upload the spool with retries and without duplicates", func() {
+		Expect(qaseapi.Spool(dir, qaseapi.NewSpooledResult(0, 73, failed), diag)).To(Succeed())
+		passed := failed
+		passed.State = types.SpecStatePassed
+		passed.LeafNodeText = "Upgrade operator"
+		Expect(qaseapi.Spool(dir, qaseapi.NewSpooledResult(5, 71, passed))).To(Succeed())
+
+		// First calls fail
+		api.failures = 2
+		report, err := client.UploadSpool(dir, 5, 3, 0)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(report.Uploaded).To(HaveLen(2))
+		Expect(api.created).To(HaveLen(2))
+		Expect(api.bodies["/attachment/ELEMENTAL"]).To(Equal("node-001 not ready"))
+
+		// Nothing uploaded twice
+		report, err = client.UploadSpool(dir, 5, 3, 0)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(report.Uploaded).To(BeEmpty())
+		Expect(report.Skipped).To(HaveLen(2))
+		Expect(api.created).To(HaveLen(2))
+	})
+
+	It("Keep failed results for a later upload", func() {
+		Expect(qaseapi.Spool(dir, qaseapi.NewSpooledResult(0, 73, failed), diag)).To(Succeed())
+
+		// No run to upload to
+		report, err := client.UploadSpool(dir, 0, 1, 0)
+		Expect(err).To(HaveOccurred())
+		Expect(report.Failed).To(HaveLen(1))
+
+		// API still failing
+		api.failures = 5
+		_, err = client.UploadSpool(dir, 5, 2, 0)
+		Expect(err).To(HaveOccurred())
+
+		// Attachment is not uploaded again
+		api.failures = 0
+		report, err = client.UploadSpool(dir, 5, 2, 0)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(report.Uploaded).To(HaveLen(1))
+		Expect(countOf(api.requests, "POST /attachment/ELEMENTAL")).To(Equal(1))
+		Expect(api.created[0]).To(ContainSubstring(`"attachments":["f00"]`))
+	})
+})
+
+// Count the occurences of a value
+func countOf(values []string, v string) int {
+	var n int
+	for _, value := range values {
+		if value == v {
+			n++
+		}
+	}
+
+	return n
+}
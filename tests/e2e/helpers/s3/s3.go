@@ -0,0 +1,280 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Format of the x-amz-date header
+	amzDateFormat = "20060102T150405Z"
+	// Hash of an empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Client is a minimal S3 client using path-style requests
+type Client struct {
+	// Endpoint URL, e.g. https://192.168.122.1:9000
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Skip TLS verification, useful with self-signed certificates
+	Insecure bool
+}
+
+/*
+Compute the SHA256 hash of a payload
+  - @param data Payload
+  - @returns Hex encoded hash
+*/
+func hashPayload(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Compute an HMAC-SHA256
+  - @param key Key
+  - @param data Data to sign
+  - @returns The HMAC
+*/
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+/*
+Sign a request with AWS Signature Version 4
+  - @param req Request to sign, all headers to sign must already be set
+  - @param payloadHash SHA256 of the payload
+  - @param accessKey Access key
+  - @param secretKey Secret key
+  - @param region Region
+  - @param t Time of the request
+  - @returns Nothing, the Authorization header is set in the request
+*/
+func Sign(req *http.Request, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	amzDate := t.UTC().Format(amzDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers, host is always signed
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// Canonical query string, sorted by key
+	query := req.URL.Query()
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, url.QueryEscape(k)+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashPayload([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+/*
+Execute a signed request
+  - @param method HTTP method
+  - @param path Path of the resource (/bucket or /bucket/key)
+  - @param query Query parameters, could be nil
+  - @param body Payload, could be nil
+  - @returns The response body or an error
+*/
+func (c *Client) do(method, path string, query url.Values, body []byte) ([]byte, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/") + path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashPayload(body)
+	}
+	Sign(req, payloadHash, c.AccessKey, c.SecretKey, c.Region, time.Now())
+
+	httpClient := &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}, //nolint:gosec
+		},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return data, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return data, nil
+}
+
+/*
+Create a bucket
+  - @param bucket Name of the bucket
+  - @returns Nothing or an error, no error if the bucket already exists
+*/
+func (c *Client) CreateBucket(bucket string) error {
+	data, err := c.do(http.MethodPut, "/"+bucket, nil, nil)
+	if err != nil && strings.Contains(string(data), "BucketAlreadyOwnedByYou") {
+		return nil
+	}
+
+	return err
+}
+
+/*
+Upload an object
+  - @param bucket Name of the bucket
+  - @param key Key of the object
+  - @param data Content of the object
+  - @returns Nothing or an error
+*/
+func (c *Client) PutObject(bucket, key string, data []byte) error {
+	_, err := c.do(http.MethodPut, "/"+bucket+"/"+key, nil, data)
+	return err
+}
+
+/*
+Download an object
+  - @param bucket Name of the bucket
+  - @param key Key of the object
+  - @returns Content of the object or an error
+*/
+func (c *Client) GetObject(bucket, key string) ([]byte, error) {
+	return c.do(http.MethodGet, "/"+bucket+"/"+key, nil, nil)
+}
+
+/*
+List objects in a bucket
+  - @param bucket Name of the bucket
+  - @param prefix Only list keys starting with this prefix
+  - @returns Keys of the objects or an error
+*/
+func (c *Client) ListObjects(bucket, prefix string) ([]string, error) {
+	query := url.Values{"list-type": {"2"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+
+	data, err := c.do(http.MethodGet, "/"+bucket, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseListObjects(data)
+}
+
+/*
+Parse a ListObjectsV2 response
+  - @param data XML response
+  - @returns Keys of the objects or an error
+*/
+func ParseListObjects(data []byte) ([]string, error) {
+	var result struct {
+		Contents []struct {
+			Key string `xml:"Key"`
+		} `xml:"Contents"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, c := range result.Contents {
+		keys = append(keys, c.Key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+const (
+	// Btrfs snapshotter
+	Btrfs = "btrfs"
+	// LoopDevice snapshotter, the default one
+	LoopDevice = "loopdevice"
+)
+
+// Default number of snapshots kept by each snapshotter
+var defaultMaxSnapshots = map[string]int{
+	Btrfs:      8,
+	LoopDevice: 4,
+}
+
+// Snapshot defines a snapshot on the node
+type Snapshot struct {
+	ID     int
+	Active bool
+}
+
+var (
+	btrfsSnapshotRegex = regexp.MustCompile(`@/\.snapshots/(\d+)/snapshot$`)
+	loopSnapshotRegex  = regexp.MustCompile(`^(\d+)$`)
+	loopActiveRegex    = regexp.MustCompile(`(\d+)(/snapshot\.img)?$`)
+)
+
+/*
+Get the snapshotter type, loopdevice is used if nothing is set
+  - @param snapType Configured snapshotter
+  - @returns The snapshotter type
+*/
+func Type(snapType string) string {
+	if snapType == "" {
+		return LoopDevice
+	}
+
+	return snapType
+}
+
+/*
+Get the default number of snapshots kept by a snapshotter
+  - @param snapType Snapshotter type
+  - @returns Maximum number of snapshots
+*/
+func MaxSnapshots(snapType string) int {
+	return defaultMaxSnapshots[Type(snapType)]
+}
+
+/*
+Detect the snapshotter used by the running system
+  - @param cl Client (node) informations
+  - @returns The snapshotter type or an error
+*/
+func Detect(cl *tools.Client) (string, error) {
+	out, err := cl.RunSSH("findmnt --noheadings --output FSTYPE,SOURCE /")
+	if err != nil {
+		return "", err
+	}
+
+	return parseDetect(out)
+}
+
+/*
+List the snapshots on the node
+  - @param cl Client (node) informations
+  - @param snapType Snapshotter type
+  - @returns The snapshots sorted by ID or an error
+*/
+func List(cl *tools.Client, snapType string) ([]Snapshot, error) {
+	switch Type(snapType) {
+	case Btrfs:
+		list, err := cl.RunSSH("btrfs subvolume list /")
+		if err != nil {
+			return nil, err
+		}
+
+		root, err := cl.RunSSH("findmnt --noheadings --output FSROOT /")
+		if err != nil {
+			return nil, err
+		}
+
+		return ParseBtrfs(list, root)
+	case LoopDevice:
+		// State partition could be mounted at different places depending on the OS version
+		dir := "$(ls -d /run/initramfs/{elemental,cos}-state/.snapshots 2>/dev/null | head -1)"
+
+		list, err := cl.RunSSH("ls -1 " + dir)
+		if err != nil {
+			return nil, err
+		}
+
+		active, err := cl.RunSSH("readlink " + dir + "/active")
+		if err != nil {
+			return nil, err
+		}
+
+		return ParseLoopDevice(list, active)
+	}
+
+	return nil, fmt.Errorf("unknown snapshotter type %q", snapType)
+}
+
+/*
+Get the active snapshot
+  - @param snapshots List of snapshots
+  - @returns The active snapshot or an error if there is not exactly one
+*/
+func Active(snapshots []Snapshot) (Snapshot, error) {
+	var active []Snapshot
+	for _, s := range snapshots {
+		if s.Active {
+			active = append(active, s)
+		}
+	}
+
+	if len(active) != 1 {
+		return Snapshot{}, fmt.Errorf("%d active snapshots found", len(active))
+	}
+
+	return active[0], nil
+}
+
+/*
+Get the snapshots added between two lists
+  - @param before Snapshots before the operation
+  - @param after Snapshots after the operation
+  - @returns The snapshots only available in the after list
+*/
+func Added(before, after []Snapshot) []Snapshot {
+	var added []Snapshot
+	for _, a := range after {
+		if !slices.ContainsFunc(before, func(b Snapshot) bool { return a.ID == b.ID }) {
+			added = append(added, a)
+		}
+	}
+
+	return added
+}
+
+/*
+Parse btrfs snapshots
+  - @param list Output of 'btrfs subvolume list /'
+  - @param root Subvolume mounted as root filesystem
+  - @returns The snapshots sorted by ID or an error
+*/
+func ParseBtrfs(list, root string) ([]Snapshot, error) {
+	activeID := -1
+	if m := btrfsSnapshotRegex.FindStringSubmatch(strings.TrimSpace(root)); m != nil {
+		activeID, _ = strconv.Atoi(m[1])
+	}
+
+	var snapshots []Snapshot
+	for _, line := range strings.Split(list, "\n") {
+		m := btrfsSnapshotRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{ID: id, Active: id == activeID})
+	}
+
+	return sortSnapshots(snapshots), nil
+}
+
+/*
+Parse loopdevice snapshots
+  - @param list List of files in the .snapshots directory
+  - @param active Target of the 'active' link
+  - @returns The snapshots sorted by ID or an error
+*/
+func ParseLoopDevice(list, active string) ([]Snapshot, error) {
+	activeID := -1
+	if m := loopActiveRegex.FindStringSubmatch(strings.TrimSpace(active)); m != nil {
+		activeID, _ = strconv.Atoi(m[1])
+	}
+
+	var snapshots []Snapshot
+	for _, line := range strings.Fields(list) {
+		m := loopSnapshotRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{ID: id, Active: id == activeID})
+	}
+
+	return sortSnapshots(snapshots), nil
+}
+
+/*
+Find the snapshotter from the root filesystem
+  - @param out FSTYPE and SOURCE of the root filesystem
+  - @returns The snapshotter type or an error
+*/
+func parseDetect(out string) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("unable to parse root filesystem %q", out)
+	}
+
+	switch {
+	case fields[0] == "btrfs":
+		return Btrfs, nil
+	case strings.HasPrefix(fields[1], "/dev/loop"):
+		return LoopDevice, nil
+	}
+
+	return "", fmt.Errorf("unknown snapshotter for root filesystem %q", out)
+}
+
+/*
+Sort snapshots by ID
+  - @param snapshots List of snapshots
+  - @returns The sorted list
+*/
+func sortSnapshots(snapshots []Snapshot) []Snapshot {
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return a.ID - b.ID })
+
+	return snapshots
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/snapshot"
+)
+
+var _ = Describe("Snapshot tests", func() {
+	It("Parse btrfs snapshots", func() {
+		list := `ID 256 gen 30 top level 5 path @
+ID 257 gen 22 top level 256 path @/.snapshots
+ID 260 gen 35 top level 257 path @/.snapshots/2/snapshot
+ID 258 gen 21 top level 257 path @/.snapshots/1/snapshot
+ID 261 gen 36 top level 256 path @/var/log
+`
+		snapshots, err := snapshot.ParseBtrfs(list, "/@/.snapshots/2/snapshot\n")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(snapshots).To(Equal([]snapshot.Snapshot{
+			{ID: 1, Active: false},
+			{ID: 2, Active: true},
+		}))
+	})
+
+	It("Parse loopdevice snapshots", func() {
+		snapshots, err := snapshot.ParseLoopDevice("1\n10\n2\nactive\npassives\n", "10/snapshot.img\n")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(snapshots).To(Equal([]snapshot.Snapshot{
+			{ID: 1, Active: false},
+			{ID: 2, Active: false},
+			{ID: 10, Active: true},
+		}))
+
+		active, err := snapshot.Active(snapshots)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(active.ID).To(Equal(10))
+	})
+
+	It("Find active and added snapshots", func() {
+		before := []snapshot.Snapshot{{ID: 1}, {ID: 2, Active: true}}
+		after := []snapshot.Snapshot{{ID: 2}, {ID: 3, Active: true}}
+
+		Expect(snapshot.Added(before, after)).To(Equal([]snapshot.Snapshot{{ID: 3, Active: true}}))
+		Expect(snapshot.Added(after, after)).To(BeEmpty())
+
+		_, err := snapshot.Active([]snapshot.Snapshot{{ID: 1}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("Get maximum number of snapshots",
+		func(snapType string, expected int) {
+			Expect(snapshot.MaxSnapshots(snapType)).To(Equal(expected))
+		},
+		Entry("btrfs", snapshot.Btrfs, 8),
+		Entry("loopdevice", snapshot.LoopDevice, 4),
+		Entry("default", "", 4),
+	)
+})
@@ -0,0 +1,175 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels used to declare the Qase case of a spec, e.g. Label("qase-9")
+const (
+	CaseLabelPrefix = "qase-"
+	// The spec is not reported to Qase
+	NoCaseLabel = CaseLabelPrefix + "none"
+)
+
+// SharedCases are the cases reported by several specs on purpose, with the reason
+var SharedCases = map[int64]string{
+	9:  "nodes are provisioned the same way by the bootstrap, UI and multi-cluster tests",
+	38: "ISO is created the same way by the single and multi-cluster tests",
+	39: "ISO is downloaded the same way by the single and multi-cluster tests",
+	62: "operator is installed the same way by the install and uninstall tests",
+	68: "libvirt is configured the same way by the single and multi-cluster tests",
+}
+
+/*
+Get the Qase case IDs declared in labels
+  - @param labels Labels of a spec
+  - @returns The case IDs, sorted, or an error if a case label is malformed
+*/
+func CaseIDs(labels []string) ([]int64, error) {
+	var (
+		ids  []int64
+		errs []error
+	)
+
+	for _, l := range labels {
+		if !strings.HasPrefix(l, CaseLabelPrefix) || l == NoCaseLabel {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimPrefix(l, CaseLabelPrefix), 10, 64)
+		if err != nil || id <= 0 {
+			errs = append(errs, fmt.Errorf("wrong case label %q", l))
+			continue
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	return ids, errors.Join(errs...)
+}
+
+/*
+Get the Qase case ID to report for a spec
+  - @param labels Labels of the spec
+  - @returns The case ID if only one is declared, -1 otherwise (the spec sets it)
+*/
+func CaseID(labels []string) int64 {
+	ids, err := CaseIDs(labels)
+	if err != nil || len(ids) != 1 {
+		return -1
+	}
+
+	return ids[0]
+}
+
+/*
+Get the labels of a spec that are not case labels
+  - @returns The labels to use as Qase tags
+*/
+func (s Spec) Tags() []string {
+	var tags []string
+	for _, l := range s.Labels {
+		if !strings.HasPrefix(l, CaseLabelPrefix) {
+			tags = append(tags, l)
+		}
+	}
+
+	return tags
+}
+
+/*
+Get the sorted case IDs of a registry
+  - @param registry Specs by case ID
+  - @returns The case IDs
+*/
+func SortedIDs(registry map[int64][]Spec) []int64 {
+	ids := make([]int64, 0, len(registry))
+	for id := range registry {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
+/*
+Check the Qase cases declared by the specs
+  - @param specs Specs to check
+  - @param shared Cases allowed to be reported by several specs
+  - @returns Nothing or an error describing all the problems
+*/
+func Lint(specs []Spec, shared map[int64]string) error {
+	var errs []error
+
+	for _, s := range specs {
+		where := fmt.Sprintf("%s:%d %q", s.File, s.Line, s.Text)
+
+		if _, err := CaseIDs(s.Labels); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where, err))
+		}
+
+		noCase := slices.Contains(s.Labels, NoCaseLabel)
+		reported := slices.ContainsFunc(s.Assigned, func(id int64) bool { return id > 0 })
+		switch {
+		case noCase && (len(s.CaseIDs) > 0 || reported):
+			errs = append(errs, fmt.Errorf("%s: %s label with a case", where, NoCaseLabel))
+		case !noCase && len(s.CaseIDs) == 0:
+			errs = append(errs, fmt.Errorf("%s: no case label, use %s if the spec is not reported", where, NoCaseLabel))
+		}
+
+		for _, id := range s.Assigned {
+			if id > 0 && !slices.Contains(s.CaseIDs, id) {
+				errs = append(errs, fmt.Errorf("%s: case %d set but not declared", where, id))
+			}
+		}
+
+		// The spec has to choose between the cases
+		if len(s.CaseIDs) > 1 {
+			for _, id := range s.CaseIDs {
+				if !slices.Contains(s.Assigned, id) {
+					errs = append(errs, fmt.Errorf("%s: case %d declared but never set", where, id))
+				}
+			}
+		}
+	}
+
+	registry := ByCaseID(specs)
+	for _, id := range SortedIDs(registry) {
+		if _, found := shared[id]; len(registry[id]) > 1 && !found {
+			var where []string
+			for _, s := range registry[id] {
+				where = append(where, fmt.Sprintf("%s:%d", s.File, s.Line))
+			}
+			errs = append(errs, fmt.Errorf("case %d declared by several specs: %s", id, strings.Join(where, ", ")))
+		}
+	}
+
+	for id := range shared {
+		if len(registry[id]) < 2 {
+			errs = append(errs, fmt.Errorf("case %d is not shared anymore", id))
+		}
+	}
+
+	return errors.Join(errs...)
+}
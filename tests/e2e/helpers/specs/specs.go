@@ -0,0 +1,232 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Name of the variable set in the specs to report to Qase
+const caseIDVar = "testCaseID"
+
+// Spec is a Ginkgo spec found in the sources
+type Spec struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Texts of the containers (Describe, Context, When)
+	Containers []string `json:"containers"`
+	Text       string   `json:"text"`
+	// Labels of the spec, including the ones of its containers
+	Labels []string `json:"labels,omitempty"`
+	// Qase cases declared with labels
+	CaseIDs []int64 `json:"caseIds,omitempty"`
+	// Values set to testCaseID in the spec, if the case depends on the test environment
+	Assigned []int64 `json:"assigned,omitempty"`
+}
+
+/*
+Get the title of the spec
+  - @returns The texts of the containers and of the spec
+*/
+func (s Spec) Title() string {
+	return strings.Join(append(slices.Clone(s.Containers), s.Text), " / ")
+}
+
+// Ginkgo functions creating a container or a spec
+var (
+	containerFuncs = []string{"Describe", "Context", "When", "FDescribe", "FContext", "FWhen", "PDescribe", "PContext", "PWhen"}
+	specFuncs      = []string{"It", "Specify", "FIt", "FSpecify", "PIt", "PSpecify"}
+)
+
+type scanner struct {
+	fset  *token.FileSet
+	specs []Spec
+}
+
+/*
+Scan the Ginkgo specs of a directory
+  - @param dir Directory with the *_test.go files
+  - @returns The specs sorted by file and line or an error
+*/
+func Scan(dir string) ([]Spec, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := scanner{fset: token.NewFileSet()}
+	for _, file := range files {
+		f, err := parser.ParseFile(s.fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		s.inspect(f, Spec{File: filepath.Base(file)})
+	}
+
+	sort.SliceStable(s.specs, func(i, j int) bool {
+		if s.specs[i].File != s.specs[j].File {
+			return s.specs[i].File < s.specs[j].File
+		}
+		return s.specs[i].Line < s.specs[j].Line
+	})
+
+	return s.specs, nil
+}
+
+/*
+Group specs by Qase case ID
+  - @param specs Specs to group
+  - @returns The specs by case ID, specs without ID are ignored
+*/
+func ByCaseID(specs []Spec) map[int64][]Spec {
+	ids := map[int64][]Spec{}
+	for _, s := range specs {
+		for _, id := range s.CaseIDs {
+			ids[id] = append(ids[id], s)
+		}
+	}
+
+	return ids
+}
+
+// Find the containers and specs in a node, parent holds what is inherited
+func (s *scanner) inspect(node ast.Node, parent Spec) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		name := funcName(call)
+		isContainer := slices.Contains(containerFuncs, name)
+		if !isContainer && !slices.Contains(specFuncs, name) {
+			return true
+		}
+
+		current := parent
+		current.Containers = slices.Clone(parent.Containers)
+		current.Labels = slices.Clone(parent.Labels)
+
+		var body *ast.BlockStmt
+		for i, arg := range call.Args {
+			switch a := arg.(type) {
+			case *ast.FuncLit:
+				body = a.Body
+			case *ast.CallExpr:
+				if funcName(a) == "Label" {
+					current.Labels = append(current.Labels, s.strings(a.Args)...)
+				}
+			}
+			if i == 0 {
+				current.Text = s.text(arg)
+			}
+		}
+
+		if isContainer {
+			current.Containers = append(current.Containers, current.Text)
+			current.Text = ""
+			if body != nil {
+				s.inspect(body, current)
+			}
+			return false
+		}
+
+		current.Line = s.fset.Position(call.Pos()).Line
+		current.CaseIDs, _ = CaseIDs(current.Labels)
+		if body != nil {
+			current.Assigned = assignedIDs(body)
+		}
+		s.specs = append(s.specs, current)
+
+		return false
+	})
+}
+
+// Get the text of an argument, the source code if not a string literal
+func (s *scanner) text(arg ast.Expr) string {
+	if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		if text, err := strconv.Unquote(lit.Value); err == nil {
+			return text
+		}
+	}
+
+	var b bytes.Buffer
+	_ = printer.Fprint(&b, s.fset, arg)
+
+	return b.String()
+}
+
+// Get the texts of arguments
+func (s *scanner) strings(args []ast.Expr) []string {
+	var texts []string
+	for _, arg := range args {
+		texts = append(texts, s.text(arg))
+	}
+
+	return texts
+}
+
+// Get the name of a called function
+func funcName(call *ast.CallExpr) string {
+	switch f := call.Fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		return f.Sel.Name
+	}
+
+	return ""
+}
+
+// Get the case IDs set in a spec
+func assignedIDs(body *ast.BlockStmt) []int64 {
+	var ids []int64
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			return true
+		}
+		if ident, ok := assign.Lhs[0].(*ast.Ident); !ok || ident.Name != caseIDVar {
+			return true
+		}
+
+		// Negative values are used to not report the spec
+		value := assign.Rhs[0]
+		sign := int64(1)
+		if unary, ok := value.(*ast.UnaryExpr); ok && unary.Op == token.SUB {
+			value = unary.X
+			sign = -1
+		}
+		if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.INT {
+			if id, err := strconv.ParseInt(lit.Value, 0, 64); err == nil {
+				ids = append(ids, sign*id)
+			}
+		}
+
+		return true
+	})
+
+	return ids
+}
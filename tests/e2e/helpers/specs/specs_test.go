@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/specs"
+)
+
+const source = `package e2e_test
+
+var _ = Describe("E2E - Bootstrapping node", Label("bootstrap"), func() {
+	It("Provision the node", Label("qase-9"), func() {
+	})
+
+	Context("With emulated TPM", Label("tpm"), func() {
+		It("Add the nodes", Label("slow", "qase-67", "qase-68"), func() {
+			if emulateTPM {
+				testCaseID = 67
+			} else {
+				testCaseID = 68
+			}
+		})
+	})
+
+	It("Without case", Label("qase-none"), func() {
+		testCaseID = -1
+	})
+})
+`
+
+// Write a source file and scan it
+func scan(src string) []specs.Spec {
+	dir := GinkgoT().TempDir()
+	Expect(os.WriteFile(filepath.Join(dir, "bootstrap_test.go"), []byte(src), 0644)).To(Succeed())
+
+	all, err := specs.Scan(dir)
+	Expect(err).To(Not(HaveOccurred()))
+
+	return all
+}
+
+var _ = Describe("Specs tests", func() {
+	It("Scan specs", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "bootstrap_test.go"), []byte(source), 0644)).To(Succeed())
+		// Not a test file
+		Expect(os.WriteFile(filepath.Join(dir, "helper.go"), []byte("package e2e\n"), 0644)).To(Succeed())
+
+		all, err := specs.Scan(dir)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(all).To(Equal([]specs.Spec{
+			{
+				File:       "bootstrap_test.go",
+				Line:       4,
+				Containers: []string{"E2E - Bootstrapping node"},
+				Text:       "Provision the node",
+				Labels:     []string{"bootstrap", "qase-9"},
+				CaseIDs:    []int64{9},
+			},
+			{
+				File:       "bootstrap_test.go",
+				Line:       8,
+				Containers: []string{"E2E - Bootstrapping node", "With emulated TPM"},
+				Text:       "Add the nodes",
+				Labels:     []string{"bootstrap", "tpm", "slow", "qase-67", "qase-68"},
+				CaseIDs:    []int64{67, 68},
+				Assigned:   []int64{67, 68},
+			},
+			{
+				File:       "bootstrap_test.go",
+				Line:       17,
+				Containers: []string{"E2E - Bootstrapping node"},
+				Text:       "Without case",
+				Labels:     []string{"bootstrap", "qase-none"},
+				Assigned:   []int64{-1},
+			},
+		}))
+
+		Expect(all[1].Title()).To(Equal("E2E - Bootstrapping node / With emulated TPM / Add the nodes"))
+		Expect(all[1].Tags()).To(Equal([]string{"bootstrap", "tpm", "slow"}))
+
+		registry := specs.ByCaseID(all)
+		Expect(registry).To(HaveLen(3))
+		Expect(registry[9]).To(HaveLen(1))
+		Expect(specs.SortedIDs(registry)).To(Equal([]int64{9, 67, 68}))
+
+		Expect(specs.Lint(all, nil)).To(Succeed())
+	})
+
+	DescribeTable("Get case ID from labels",
+		func(labels []string, expected int64) {
+			Expect(specs.CaseID(labels)).To(Equal(expected))
+		},
+		Entry("one case", []string{"bootstrap", "qase-9"}, int64(9)),
+		Entry("several cases", []string{"qase-59", "qase-60"}, int64(-1)),
+		Entry("no case", []string{"qase-none"}, int64(-1)),
+		Entry("malformed case", []string{"qase-nine"}, int64(-1)),
+	)
+
+	DescribeTable("Lint specs",
+		func(src string, shared map[int64]string, problem string) {
+			err := specs.Lint(scan("package e2e_test\n\n"+src), shared)
+			if problem == "" {
+				Expect(err).To(Not(HaveOccurred()))
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(problem)))
+			}
+		},
+		Entry("missing case", `var _ = It("a", func() {})`, nil, "no case label"),
+		Entry("malformed case", `var _ = It("a", Label("qase-0"), func() {})`, nil, "wrong case label"),
+		Entry("no case with a case", `var _ = It("a", Label("qase-none", "qase-9"), func() {})`, nil, "label with a case"),
+		Entry("undeclared case", `var _ = It("a", Label("qase-9"), func() { testCaseID = 10 })`, nil, "case 10 set but not declared"),
+		Entry("case never set", `var _ = It("a", Label("qase-9", "qase-10"), func() { testCaseID = 9 })`, nil, "case 10 declared but never set"),
+		Entry("duplicate case", `var _ = It("a", Label("qase-9"), func() {})
+var _ = It("b", Label("qase-9"), func() {})`, nil, "case 9 declared by several specs"),
+		Entry("shared case", `var _ = It("a", Label("qase-9"), func() {})
+var _ = It("b", Label("qase-9"), func() {})`, map[int64]string{9: "same test"}, ""),
+		Entry("case not shared anymore", `var _ = It("a", Label("qase-9"), func() {})`, map[int64]string{9: "same test"}, "case 9 is not shared anymore"),
+	)
+
+	It("Lint the E2E specs", func() {
+		all, err := specs.Scan("../..")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(all).To(Not(BeEmpty()))
+		Expect(specs.Lint(all, specs.SharedCases)).To(Succeed())
+	})
+})
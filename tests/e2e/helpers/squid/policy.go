@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package squid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy describes the expected egress traffic through the proxy
+type Policy struct {
+	// Regexes of hosts that must be reached through the proxy
+	MustProxy []string `yaml:"mustProxy"`
+	// Regexes of hosts that must never be contacted through the proxy
+	Forbidden []string `yaml:"forbidden"`
+	// All nodes must use the proxy
+	Nodes bool `yaml:"nodes"`
+	// The upstream cluster (not a node) must use the proxy
+	Upstream bool `yaml:"upstream"`
+}
+
+// Policies are the policies by proxy mode
+type Policies map[string]Policy
+
+/*
+Load policies from a file
+  - @param file Policies in YAML format
+  - @returns The policies or an error
+*/
+func LoadPolicies(file string) (Policies, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policies
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+/*
+Check that the requests follow the policy
+  - @param entries Requests of the access log
+  - @param nodes IP addresses of the nodes
+  - @returns Nothing or an error describing all the violations
+*/
+func (p Policy) Check(entries []Entry, nodes []string) error {
+	var errs []error
+
+	for _, pattern := range p.MustProxy {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Succeeded() && re.MatchString(e.Host) }) {
+			errs = append(errs, fmt.Errorf("no successful request to %q through the proxy", pattern))
+		}
+	}
+
+	for _, pattern := range p.Forbidden {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Only report each client/host once
+		reported := map[string]bool{}
+		for _, e := range entries {
+			if re.MatchString(e.Host) && !reported[e.Client+" "+e.Host] {
+				reported[e.Client+" "+e.Host] = true
+				errs = append(errs, fmt.Errorf("forbidden host %s contacted by %s", e.Host, e.Client))
+			}
+		}
+	}
+
+	if p.Nodes {
+		if len(nodes) == 0 {
+			errs = append(errs, errors.New("no node to check"))
+		}
+		for _, n := range nodes {
+			if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Succeeded() && e.Client == n }) {
+				errs = append(errs, fmt.Errorf("node %s did not use the proxy", n))
+			}
+		}
+	}
+
+	if p.Upstream {
+		if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Succeeded() && !slices.Contains(nodes, e.Client) }) {
+			errs = append(errs, errors.New("upstream cluster did not use the proxy"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package squid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a request in a squid access log (native format)
+type Entry struct {
+	Time time.Time
+	// Client IP address
+	Client string
+	// Result code, e.g. TCP_TUNNEL
+	Code   string
+	Status int
+	Bytes  int64
+	Method string
+	URL    string
+	// Destination host, without port
+	Host string
+}
+
+/*
+Check if the request has been successfully forwarded
+  - @returns true if the HTTP status is 2xx or 3xx
+*/
+func (e Entry) Succeeded() bool {
+	return e.Status >= 200 && e.Status < 400
+}
+
+/*
+Get the destination host of a request
+  - @param method HTTP method
+  - @param u URL as logged by squid, host:port for CONNECT
+  - @returns The host name or IP, without port
+*/
+func HostOf(method, u string) string {
+	if method == "CONNECT" {
+		if host, _, err := net.SplitHostPort(u); err == nil {
+			return host
+		}
+		return u
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Hostname()
+}
+
+/*
+Parse a line of a squid access log
+  - @param line Line in squid native format
+  - @returns The request or an error
+*/
+func ParseLine(line string) (Entry, error) {
+	// time elapsed client code/status bytes method URL user hierarchy/peer type
+	fields := strings.Fields(line)
+	if len(fields) < 7 {
+		return Entry{}, fmt.Errorf("not enough fields in %q", line)
+	}
+
+	// Timestamp is in seconds with milliseconds
+	sec, msec, _ := strings.Cut(fields[0], ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("wrong timestamp in %q: %w", line, err)
+	}
+	ms, err := strconv.ParseInt("0"+msec, 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("wrong timestamp in %q: %w", line, err)
+	}
+
+	code, status, found := strings.Cut(fields[3], "/")
+	if !found {
+		return Entry{}, fmt.Errorf("wrong result code in %q", line)
+	}
+	st, err := strconv.Atoi(status)
+	if err != nil {
+		return Entry{}, fmt.Errorf("wrong status in %q: %w", line, err)
+	}
+
+	b, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("wrong size in %q: %w", line, err)
+	}
+
+	return Entry{
+		Time:   time.UnixMilli(s*1000 + ms).UTC(),
+		Client: fields[2],
+		Code:   code,
+		Status: st,
+		Bytes:  b,
+		Method: fields[5],
+		URL:    fields[6],
+		Host:   HostOf(fields[5], fields[6]),
+	}, nil
+}
+
+/*
+Parse a squid access log
+  - @param r Content of the access log
+  - @returns The requests or an error on the first malformed line
+*/
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		e, err := ParseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Requests gives the number of requests per client and destination host
+type Requests map[string]map[string]int
+
+/*
+Group requests by client and destination host
+  - @param entries Requests of the access log
+  - @returns The number of requests per client and host
+*/
+func Group(entries []Entry) Requests {
+	r := Requests{}
+	for _, e := range entries {
+		if r[e.Client] == nil {
+			r[e.Client] = map[string]int{}
+		}
+		r[e.Client][e.Host]++
+	}
+
+	return r
+}
+
+/*
+Get the requests in text format
+  - @returns One line per client and host, sorted
+*/
+func (r Requests) String() string {
+	clients := make([]string, 0, len(r))
+	for c := range r {
+		clients = append(clients, c)
+	}
+	sort.Strings(clients)
+
+	var b strings.Builder
+	for _, c := range clients {
+		hosts := make([]string, 0, len(r[c]))
+		for h := range r[c] {
+			hosts = append(hosts, h)
+		}
+		sort.Strings(hosts)
+
+		for _, h := range hosts {
+			fmt.Fprintf(&b, "%s %s %d\n", c, h, r[c][h])
+		}
+	}
+
+	return b.String()
+}
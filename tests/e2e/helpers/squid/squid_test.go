@@ -0,0 +1,103 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package squid_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/squid"
+)
+
+const accessLog = `1736157600.123    512 192.168.122.2 TCP_TUNNEL/200 4242 CONNECT registry-1.docker.io:443 - HIER_DIRECT/44.208.254.194 -
+1736157601.000     35 192.168.122.2 TCP_MISS/200 1024 GET http://releases.rancher.com/install.sh - HIER_DIRECT/104.18.0.1 text/plain
+1736157602.000     12 192.168.122.3 TCP_TUNNEL/200 2048 CONNECT github.com:443 - HIER_DIRECT/140.82.121.4 -
+1736157603.000      0 172.17.0.1 TCP_DENIED/403 3900 CONNECT rancher.example.com:22 - HIER_NONE/- text/html
+
+`
+
+var _ = Describe("Squid tests", func() {
+	It("Parse access log", func() {
+		entries, err := squid.Parse(strings.NewReader(accessLog))
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(entries).To(HaveLen(4))
+
+		Expect(entries[0]).To(Equal(squid.Entry{
+			Time:   time.Unix(1736157600, 123000000).UTC(),
+			Client: "192.168.122.2",
+			Code:   "TCP_TUNNEL",
+			Status: 200,
+			Bytes:  4242,
+			Method: "CONNECT",
+			URL:    "registry-1.docker.io:443",
+			Host:   "registry-1.docker.io",
+		}))
+		Expect(entries[1].Host).To(Equal("releases.rancher.com"))
+		Expect(entries[3].Succeeded()).To(BeFalse())
+
+		_, err = squid.Parse(strings.NewReader("1736157600.123 512 192.168.122.2 TCP_TUNNEL 0 CONNECT github.com:443"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Group requests", func() {
+		entries, err := squid.Parse(strings.NewReader(accessLog))
+		Expect(err).To(Not(HaveOccurred()))
+
+		requests := squid.Group(entries)
+		Expect(requests).To(HaveLen(3))
+		Expect(requests["192.168.122.2"]).To(Equal(map[string]int{"registry-1.docker.io": 1, "releases.rancher.com": 1}))
+		Expect(requests.String()).To(Equal(`172.17.0.1 rancher.example.com 1
+192.168.122.2 registry-1.docker.io 1
+192.168.122.2 releases.rancher.com 1
+192.168.122.3 github.com 1
+`))
+	})
+
+	DescribeTable("Check policy",
+		func(p squid.Policy, nodes []string, valid bool) {
+			entries, err := squid.Parse(strings.NewReader(accessLog))
+			Expect(err).To(Not(HaveOccurred()))
+
+			err = p.Check(entries, nodes)
+			if valid {
+				Expect(err).To(Not(HaveOccurred()))
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("empty policy", squid.Policy{}, nil, true),
+		Entry("proxied hosts", squid.Policy{MustProxy: []string{`docker\.io`, `rancher\.com$`}}, nil, true),
+		Entry("host not proxied", squid.Policy{MustProxy: []string{`suse\.com$`}}, nil, false),
+		Entry("denied request is not proxied", squid.Policy{MustProxy: []string{`example\.com$`}}, nil, false),
+		Entry("forbidden host", squid.Policy{Forbidden: []string{`^github\.com$`}}, nil, false),
+		Entry("invalid regex", squid.Policy{Forbidden: []string{`(`}}, nil, false),
+		Entry("all nodes use the proxy", squid.Policy{Nodes: true}, []string{"192.168.122.2", "192.168.122.3"}, true),
+		Entry("node bypassed the proxy", squid.Policy{Nodes: true}, []string{"192.168.122.2", "192.168.122.4"}, false),
+		Entry("no node", squid.Policy{Nodes: true}, nil, false),
+		Entry("upstream did not use the proxy", squid.Policy{Upstream: true}, []string{"192.168.122.2", "192.168.122.3"}, false),
+		Entry("upstream uses the proxy", squid.Policy{Upstream: true}, []string{"192.168.122.3"}, true),
+	)
+
+	It("Load policies", func() {
+		policies, err := squid.LoadPolicies("../../../assets/proxy-policy.yaml")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(policies).To(HaveKey("elemental"))
+		Expect(policies).To(HaveKey("rancher"))
+		Expect(policies["elemental"].Nodes).To(BeTrue())
+		Expect(policies["rancher"].Forbidden).To(Equal(policies["elemental"].Forbidden))
+	})
+})
@@ -0,0 +1,236 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2/reporters"
+	"github.com/onsi/ginkgo/v2/types"
+)
+
+// Outcomes of a step
+const (
+	Passed  = "passed"
+	Failed  = "failed"
+	Skipped = "skipped"
+)
+
+// Step is a By of a spec
+type Step struct {
+	Spec     string    `json:"spec"`
+	Text     string    `json:"text"`
+	Location string    `json:"location"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	// Duration in seconds
+	Duration float64 `json:"duration"`
+	Node     string  `json:"node,omitempty"`
+	Cluster  string  `json:"cluster,omitempty"`
+	Outcome  string  `json:"outcome"`
+	// Set if the step failed
+	Failure string `json:"failure,omitempty"`
+}
+
+// Extractor finds node and cluster names in the text of a step
+type Extractor struct {
+	Node    *regexp.Regexp
+	Cluster *regexp.Regexp
+}
+
+// Get the node and cluster of a step
+func (e Extractor) extract(text string) (string, string) {
+	var node, cluster string
+	if e.Node != nil {
+		node = e.Node.FindString(text)
+	}
+	if e.Cluster != nil {
+		// Hostnames may contain the cluster name
+		if node != "" {
+			text = strings.ReplaceAll(text, node, "")
+		}
+		cluster = e.Cluster.FindString(text)
+	}
+
+	return node, cluster
+}
+
+/*
+Get all the steps of a suite
+  - @param report Report of the suite
+  - @param e Extractor for node and cluster names
+  - @returns The steps of all specs, in order
+*/
+func Steps(report types.Report, e Extractor) []Step {
+	var steps []Step
+	for _, spec := range report.SpecReports {
+		steps = append(steps, SpecSteps(spec, e)...)
+	}
+
+	return steps
+}
+
+/*
+Get all the steps of a spec
+  - @param spec Report of the spec
+  - @param e Extractor for node and cluster names
+  - @returns The steps of the spec, in start order
+*/
+func SpecSteps(spec types.SpecReport, e Extractor) []Step {
+	var (
+		steps []Step
+		// Steps can run in parallel (goroutines), so they are matched by text
+		started = map[string][]int{}
+	)
+
+	for _, event := range spec.SpecEvents {
+		switch event.SpecEventType {
+		case types.SpecEventByStart:
+			node, cluster := e.extract(event.Message)
+			steps = append(steps, Step{
+				Spec:     spec.FullText(),
+				Text:     event.Message,
+				Location: event.CodeLocation.String(),
+				Start:    event.TimelineLocation.Time,
+				Node:     node,
+				Cluster:  cluster,
+			})
+			started[event.Message] = append(started[event.Message], len(steps)-1)
+		case types.SpecEventByEnd:
+			idx := started[event.Message]
+			if len(idx) == 0 {
+				continue
+			}
+			steps[idx[len(idx)-1]].End = event.TimelineLocation.Time
+			started[event.Message] = idx[:len(idx)-1]
+		}
+	}
+
+	failed := spec.State.Is(types.SpecStateFailureStates)
+	failureTime := spec.Failure.TimelineLocation.Time
+	for i := range steps {
+		s := &steps[i]
+
+		// Step without callback or interrupted by a failure
+		if s.End.IsZero() {
+			s.End = spec.EndTime
+		}
+		s.Duration = s.End.Sub(s.Start).Seconds()
+
+		switch {
+		case failed && !failureTime.Before(s.Start) && !failureTime.After(s.End):
+			s.Outcome = Failed
+			s.Failure = spec.Failure.Message
+		case spec.State.Is(types.SpecStateSkipped | types.SpecStatePending):
+			s.Outcome = Skipped
+		default:
+			s.Outcome = Passed
+		}
+	}
+
+	return steps
+}
+
+/*
+Write the steps as a JSON timeline
+  - @param steps Steps to write
+  - @param file Name of the JSON file
+  - @returns Nothing or an error
+*/
+func WriteJSON(steps []Step, file string) error {
+	// An empty timeline is an empty list, not null
+	if steps == nil {
+		steps = []Step{}
+	}
+
+	data, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0644)
+}
+
+/*
+Write the steps as a JUnit report, one test case per step
+  - @param steps Steps to write
+  - @param name Name of the test suite
+  - @param file Name of the XML file
+  - @returns Nothing or an error
+*/
+func WriteJUnit(steps []Step, name, file string) error {
+	suite := reporters.JUnitTestSuite{
+		Name:  name,
+		Tests: len(steps),
+	}
+
+	var start, end time.Time
+
+	for _, s := range steps {
+		tc := reporters.JUnitTestCase{
+			Name:      s.Text,
+			Classname: s.Spec,
+			Status:    s.Outcome,
+			Time:      s.Duration,
+		}
+
+		switch s.Outcome {
+		case Failed:
+			suite.Failures++
+			tc.Failure = &reporters.JUnitFailure{
+				Message:     s.Failure,
+				Type:        "failed",
+				Description: s.Location,
+			}
+		case Skipped:
+			suite.Skipped++
+			tc.Skipped = &reporters.JUnitSkipped{Message: "skipped"}
+		}
+
+		// Steps can be nested, so durations cannot be added
+		if start.IsZero() || s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if !start.IsZero() {
+		suite.Timestamp = start.Format("2006-01-02T15:04:05")
+		suite.Time = end.Sub(start).Seconds()
+	}
+
+	suites := reporters.JUnitTestSuites{
+		Tests:      suite.Tests,
+		Failures:   suite.Failures,
+		Disabled:   suite.Skipped,
+		Time:       suite.Time,
+		TestSuites: []reporters.JUnitTestSuite{suite},
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, append([]byte(xml.Header), data...), 0644)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timing_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/timing"
+)
+
+var start = time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+
+// Create a By event
+func event(t types.SpecEventType, text string, seconds int) types.SpecEvent {
+	return types.SpecEvent{
+		SpecEventType:    t,
+		Message:          text,
+		TimelineLocation: types.TimelineLocation{Time: start.Add(time.Duration(seconds) * time.Second)},
+	}
+}
+
+// Create a spec with steps running in parallel on two nodes, the second one failing
+func failedSpec() types.SpecReport {
+	return types.SpecReport{
+		LeafNodeText: "Upgrade node",
+		State:        types.SpecStateFailed,
+		EndTime:      start.Add(100 * time.Second),
+		Failure: types.Failure{
+			Message:          "timeout",
+			TimelineLocation: types.TimelineLocation{Time: start.Add(90 * time.Second)},
+		},
+		SpecEvents: types.SpecEvents{
+			event(types.SpecEventByStart, "Upgrading cluster cluster-k3s", 0),
+			event(types.SpecEventByStart, "Checking node-001", 10),
+			event(types.SpecEventByStart, "Checking node-002", 11),
+			event(types.SpecEventByEnd, "Checking node-001", 40),
+		},
+	}
+}
+
+var _ = Describe("Timing tests", func() {
+	e := timing.Extractor{
+		Node:    regexp.MustCompile(`node-\d{3}`),
+		Cluster: regexp.MustCompile(`cluster-[a-z0-9-]+`),
+	}
+
+	It("Get steps of a spec", func() {
+		steps := timing.SpecSteps(failedSpec(), e)
+		Expect(steps).To(HaveLen(3))
+
+		Expect(steps[0].Cluster).To(Equal("cluster-k3s"))
+		Expect(steps[0].Outcome).To(Equal(timing.Failed))
+		Expect(steps[0].Duration).To(Equal(100.0))
+
+		Expect(steps[1].Node).To(Equal("node-001"))
+		Expect(steps[1].Outcome).To(Equal(timing.Passed))
+		Expect(steps[1].Duration).To(Equal(30.0))
+
+		Expect(steps[2].Node).To(Equal("node-002"))
+		Expect(steps[2].Outcome).To(Equal(timing.Failed))
+		Expect(steps[2].Failure).To(Equal("timeout"))
+	})
+
+	It("Do not take node name as cluster name", func() {
+		spec := types.SpecReport{
+			State:   types.SpecStatePassed,
+			EndTime: start.Add(time.Minute),
+			SpecEvents: types.SpecEvents{
+				event(types.SpecEventByStart, "Restarting node-cluster-1-001", 0),
+			},
+		}
+
+		steps := timing.SpecSteps(spec, timing.Extractor{
+			Node:    regexp.MustCompile(`node-[a-z0-9-]*\d{3}`),
+			Cluster: regexp.MustCompile(`cluster-[a-z0-9-]+`),
+		})
+		Expect(steps).To(HaveLen(1))
+		Expect(steps[0].Node).To(Equal("node-cluster-1-001"))
+		Expect(steps[0].Cluster).To(BeEmpty())
+	})
+
+	It("Write reports", func() {
+		dir := GinkgoT().TempDir()
+		steps := timing.SpecSteps(failedSpec(), e)
+
+		jsonFile := filepath.Join(dir, "steps.json")
+		Expect(timing.WriteJSON(steps, jsonFile)).To(Succeed())
+		data, err := os.ReadFile(jsonFile)
+		Expect(err).To(Not(HaveOccurred()))
+
+		var read []timing.Step
+		Expect(json.Unmarshal(data, &read)).To(Succeed())
+		Expect(read).To(HaveLen(3))
+		Expect(read[2].Node).To(Equal("node-002"))
+
+		junitFile := filepath.Join(dir, "steps.xml")
+		Expect(timing.WriteJUnit(steps, "E2E steps", junitFile)).To(Succeed())
+		data, err = os.ReadFile(junitFile)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(ContainSubstring(`<testsuites tests="3" disabled="0" errors="0" failures="2" time="100">`))
+		Expect(string(data)).To(ContainSubstring(`<testcase name="Checking node-001" classname="Upgrade node" status="passed" time="30">`))
+	})
+
+	It("Write an empty timeline", func() {
+		jsonFile := filepath.Join(GinkgoT().TempDir(), "steps.json")
+		Expect(timing.WriteJSON(timing.Steps(types.Report{}, e), jsonFile)).To(Succeed())
+		data, err := os.ReadFile(jsonFile)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(string(data)).To(Equal("[]"))
+	})
+})
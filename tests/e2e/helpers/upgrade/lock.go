@@ -0,0 +1,54 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+// Lock file used by suc-upgrade, truncated each time the upgrade job starts
+const lockFile = "/run/elemental/upgrade.lock"
+
+/*
+Get the last time suc-upgrade has been started on a node
+  - @param cl Client (node) informations
+  - @returns Modification time of the lock file (0 if it does not exist) or an error
+  - @note The lock file is in /run, so this is reset at each reboot
+*/
+func LockTime(cl *tools.Client) (int64, error) {
+	out, err := cl.RunSSH("stat --format %Y " + lockFile + " 2>/dev/null || echo 0")
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+}
+
+/*
+Check if suc-upgrade is running on a node
+  - @param cl Client (node) informations
+  - @returns true if the lock is held or an error
+*/
+func IsRunning(cl *tools.Client) (bool, error) {
+	out, err := cl.RunSSH("flock --nonblock " + lockFile + " true && echo free || echo locked")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(out) == "locked", nil
+}
@@ -0,0 +1,349 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Decision taken by suc-upgrade for a given host/image couple
+type Decision int
+
+const (
+	// Upgrade is executed
+	Upgrade Decision = iota
+	// AlreadyDone means that host and image are the same, nothing is done
+	AlreadyDone
+	// Refused means that the host has a higher version and FORCE is not set
+	Refused
+)
+
+// OSRelease contains the key/value pairs of an os-release file
+type OSRelease map[string]string
+
+/*
+Return the string representation of a decision
+  - @returns Name of the decision
+*/
+func (d Decision) String() string {
+	switch d {
+	case Upgrade:
+		return "upgrade"
+	case AlreadyDone:
+		return "already-done"
+	case Refused:
+		return "refused"
+	}
+
+	return "unknown"
+}
+
+/*
+Parse an os-release file content
+  - @param data Content of the os-release file
+  - @returns The parsed key/value pairs
+*/
+func ParseOSRelease(data string) OSRelease {
+	release := OSRelease{}
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+
+		// Skip empty lines and comments, as the shell would do
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		// Remove the quotes if any
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "'")
+		}
+
+		release[key] = value
+	}
+
+	return release
+}
+
+/*
+Create an os-release structure from a container image reference
+  - @param image Container image, e.g. registry.example.com/repo/os:1.2.3
+  - @returns The IMAGE, IMAGE_REPO and IMAGE_TAG keys as set during the image build
+*/
+func NewOSReleaseFromImage(image string) OSRelease {
+	repo, tag := image, ""
+
+	// A ':' before the last '/' is a registry port, not a tag
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+
+	return OSRelease{
+		"IMAGE":      image,
+		"IMAGE_REPO": repo,
+		"IMAGE_TAG":  tag,
+	}
+}
+
+/*
+Get the image repository
+  - @returns Value of IMAGE_REPO
+*/
+func (o OSRelease) Repo() string {
+	return o["IMAGE_REPO"]
+}
+
+/*
+Get the image tag
+  - @returns Value of IMAGE_TAG
+*/
+func (o OSRelease) Tag() string {
+	return o["IMAGE_TAG"]
+}
+
+/*
+Compare two image tags, as 'sort -V' does in suc-upgrade (this is not semver)
+  - @param a First tag
+  - @param b Second tag
+  - @returns -1 if a < b, 0 if a == b and +1 if a > b
+*/
+func CompareVersions(a, b string) int {
+	if c := fileVersionCompare(a, b); c != 0 {
+		return sign(c)
+	}
+
+	// 'sort' falls back to a byte comparison for versions considered equal
+	return strings.Compare(a, b)
+}
+
+/*
+Check if the image version is higher than the host one, as isHigherVersion in suc-upgrade
+  - @param host os-release of the host
+  - @param img os-release of the upgrade image
+  - @returns true if the upgrade can be done
+*/
+func IsHigherVersion(host, img OSRelease) bool {
+	// If images are from different repositories the version check is omitted
+	if host.Repo() != img.Repo() {
+		return true
+	}
+
+	// Without knowing the version in the host, all image versions are considered higher
+	if host.Tag() == "" {
+		return true
+	}
+
+	if host.Tag() == img.Tag() {
+		return false
+	}
+
+	return CompareVersions(img.Tag(), host.Tag()) > 0
+}
+
+/*
+Check if the image is the same as the host, as isEqualVersion in suc-upgrade
+  - @param host os-release of the host
+  - @param img os-release of the upgrade image, only its keys are compared as it can be partial (see NewOSReleaseFromImage)
+  - @returns true if the host has the same values for all the keys of the image
+*/
+func IsEqualVersion(host, img OSRelease) bool {
+	if len(img) == 0 {
+		return len(host) == 0
+	}
+
+	for key, value := range img {
+		if v, found := host[key]; !found || v != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Predict what suc-upgrade will do
+  - @param host os-release of the host
+  - @param img os-release of the upgrade image
+  - @param force Value of FORCE variable
+  - @param recoveryOnly Value of UPGRADE_RECOVERY_ONLY variable
+  - @returns The decision taken by suc-upgrade
+*/
+func Decide(host, img OSRelease, force, recoveryOnly bool) Decision {
+	if IsEqualVersion(host, img) {
+		return AlreadyDone
+	}
+
+	if !force && !recoveryOnly && !IsHigherVersion(host, img) {
+		return Refused
+	}
+
+	return Upgrade
+}
+
+/*
+Compare two versions, as filevercmp from gnulib used by 'sort -V'
+  - @param a First version
+  - @param b Second version
+  - @returns A negative value if a < b, 0 if a == b and a positive value if a > b
+*/
+func fileVersionCompare(a, b string) int {
+	// Empty versions first, then ".", "..", and the names starting with a "."
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	case a[0] == '.' && b[0] != '.':
+		return -1
+	case a[0] != '.' && b[0] == '.':
+		return 1
+	case a[0] == '.':
+		for _, special := range []string{".", ".."} {
+			if a == special {
+				return -1
+			}
+			if b == special {
+				return 1
+			}
+		}
+	}
+
+	// File suffixes (e.g. ".tar.gz") are only compared if the rest is equal
+	pa, pb := filePrefixLen(a), filePrefixLen(b)
+	if c := versionRevCompare(a[:pa], b[:pb]); c != 0 || (pa == len(a) && pb == len(b)) {
+		return c
+	}
+
+	return versionRevCompare(a, b)
+}
+
+/*
+Get the length of a version without its file suffix, matched by (\.[A-Za-z~][A-Za-z0-9~]*)*$
+  - @param s Version
+  - @returns Length of the version without suffix
+*/
+func filePrefixLen(s string) int {
+	prefixLen := 0
+	for i := 0; i < len(s); {
+		i++
+		prefixLen = i
+		for i+1 < len(s) && s[i] == '.' && (isAlpha(s[i+1]) || s[i+1] == '~') {
+			for i += 2; i < len(s) && (isAlpha(s[i]) || isDigit(s[i]) || s[i] == '~'); i++ {
+			}
+		}
+	}
+
+	return prefixLen
+}
+
+/*
+Get the weight of a character, letters are before the other characters and '~' is before anything
+  - @param s Version
+  - @param i Position of the character
+  - @returns The weight of the character
+*/
+func order(s string, i int) int {
+	switch {
+	case i == len(s):
+		return -1
+	case isDigit(s[i]):
+		return 0
+	case isAlpha(s[i]):
+		return int(s[i])
+	case s[i] == '~':
+		return -2
+	}
+
+	return int(s[i]) + 256
+}
+
+/*
+Compare two versions, as verrevcmp from gnulib
+  - @param a First version
+  - @param b Second version
+  - @returns A negative value if a < b, 0 if a == b and a positive value if a > b
+*/
+func versionRevCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// Non-digit parts are compared character by character
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			if ca, cb := order(a, i), order(b, j); ca != cb {
+				return ca - cb
+			}
+			i++
+			j++
+		}
+
+		// Digit parts are compared numerically
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && j < len(b) && isDigit(a[i]) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+
+	return 0
+}
+
+// Check if a character is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Check if a character is an ASCII letter
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Get the sign of a value
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/upgrade"
+)
+
+const osRepo = "registry.example.com/suse/sl-micro/6.1/baremetal-os-container"
+
+var _ = Describe("Upgrade decision tests", func() {
+	It("Parse os-release file", func() {
+		release := upgrade.ParseOSRelease(`# Comment
+NAME="SL-Micro"
+VERSION_ID='6.1'
+IMAGE_REPO="` + osRepo + `"
+IMAGE_TAG="2.2.0-4.4"
+IMAGE="` + osRepo + `:2.2.0-4.4"
+TIMESTAMP=20241112093046
+`)
+		Expect(release).To(HaveLen(6))
+		Expect(release["NAME"]).To(Equal("SL-Micro"))
+		Expect(release["VERSION_ID"]).To(Equal("6.1"))
+		Expect(release["TIMESTAMP"]).To(Equal("20241112093046"))
+		Expect(release.Repo()).To(Equal(osRepo))
+		Expect(release.Tag()).To(Equal("2.2.0-4.4"))
+	})
+
+	DescribeTable("Create os-release from image",
+		func(image, repo, tag string) {
+			release := upgrade.NewOSReleaseFromImage(image)
+			Expect(release.Repo()).To(Equal(repo))
+			Expect(release.Tag()).To(Equal(tag))
+			Expect(release["IMAGE"]).To(Equal(image))
+		},
+		Entry("with tag", osRepo+":2.2.0-4.4", osRepo, "2.2.0-4.4"),
+		Entry("without tag", osRepo, osRepo, ""),
+		Entry("with registry port", "rancher-manager.test:5000/rancher/os:latest", "rancher-manager.test:5000/rancher/os", "latest"),
+		Entry("with registry port and without tag", "rancher-manager.test:5000/rancher/os", "rancher-manager.test:5000/rancher/os", ""),
+	)
+
+	DescribeTable("Compare versions",
+		func(a, b string, expected int) {
+			Expect(upgrade.CompareVersions(a, b)).To(Equal(expected))
+			Expect(upgrade.CompareVersions(b, a)).To(Equal(-expected))
+		},
+		Entry("same version", "2.2.0-4.4", "2.2.0-4.4", 0),
+		Entry("semver patch", "2.2.1", "2.2.0", 1),
+		Entry("semver minor with two digits", "2.10.0", "2.9.0", 1),
+		Entry("semver with v prefix", "v2.10.0", "v2.9.0", 1),
+		Entry("OBS release number", "2.2.0-4.10", "2.2.0-4.9", 1),
+		Entry("pre-release is not semver", "1.0.0-rc1", "1.0.0", 1),
+		Entry("OBS release number without base", "2.2.0-4.4", "2.2.0", 1),
+		Entry("tilde before release", "1.0", "1.0~rc1", 1),
+		Entry("leading zeros", "1.00", "1.0", 1),
+		Entry("file suffix", "1.0.0-1.tar.gz", "1.0.0.tar.gz", 1),
+		Entry("v prefix", "v2", "2", 1),
+		Entry("semver build metadata", "1.0.0+build2", "1.0.0+build1", 1),
+		Entry("build metadata with higher version", "1.0.1+build1", "1.0.0+build2", 1),
+		Entry("non semver tags", "6.1-1.10", "6.1-1.9", 1),
+		Entry("non semver tag with letters", "latest", "6.1", 1),
+	)
+
+	DescribeTable("Predict suc-upgrade decision",
+		func(host, img upgrade.OSRelease, force, recoveryOnly bool, expected upgrade.Decision) {
+			Expect(upgrade.Decide(host, img, force, recoveryOnly)).To(Equal(expected))
+		},
+		Entry("higher version",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.5"),
+			false, false, upgrade.Upgrade),
+		Entry("higher semver version",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.9.0"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.10.0"),
+			false, false, upgrade.Upgrade),
+		Entry("identical release",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			false, false, upgrade.AlreadyDone),
+		Entry("identical release with FORCE",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			true, false, upgrade.AlreadyDone),
+		Entry("host with the image release",
+			upgrade.ParseOSRelease("NAME=\"SL-Micro\"\nIMAGE_REPO=\""+osRepo+"\"\nIMAGE_TAG=\"2.2.0-4.4\"\nIMAGE=\""+osRepo+":2.2.0-4.4\"\n"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			false, false, upgrade.AlreadyDone),
+		Entry("same tag but different release content",
+			upgrade.OSRelease{"IMAGE_REPO": osRepo, "IMAGE_TAG": "2.2.0-4.4", "TIMESTAMP": "1"},
+			upgrade.OSRelease{"IMAGE_REPO": osRepo, "IMAGE_TAG": "2.2.0-4.4", "TIMESTAMP": "2"},
+			false, false, upgrade.Refused),
+		Entry("lower version",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.5"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			false, false, upgrade.Refused),
+		Entry("forced downgrade",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.5"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			true, false, upgrade.Upgrade),
+		Entry("lower version with recovery only upgrade",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.5"),
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.4"),
+			false, true, upgrade.Upgrade),
+		Entry("lower build metadata",
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0+build2"),
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0+build1"),
+			false, false, upgrade.Refused),
+		Entry("higher build metadata",
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0+build1"),
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0+build2"),
+			false, false, upgrade.Upgrade),
+		Entry("pre-release after release",
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0"),
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0-rc1"),
+			false, false, upgrade.Upgrade),
+		Entry("different repositories",
+			upgrade.NewOSReleaseFromImage(osRepo+":2.2.0-4.5"),
+			upgrade.NewOSReleaseFromImage("registry.example.com/other/os:1.0.0"),
+			false, false, upgrade.Upgrade),
+		Entry("missing host tag",
+			upgrade.OSRelease{"IMAGE_REPO": osRepo},
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0"),
+			false, false, upgrade.Upgrade),
+		Entry("missing host release",
+			upgrade.OSRelease{},
+			upgrade.NewOSReleaseFromImage(osRepo+":1.0.0"),
+			false, false, upgrade.Upgrade),
+	)
+})
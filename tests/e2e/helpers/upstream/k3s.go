@@ -0,0 +1,129 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstream
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+const k3sConfigDir = "/etc/rancher/k3s"
+
+// K3s upstream cluster
+type K3s struct {
+	Options
+}
+
+// Name of the distribution
+func (c *K3s) Name() string {
+	return "K3s"
+}
+
+// Environment of the installation script
+func (c *K3s) Env() []string {
+	env := []string{"INSTALL_K3S_EXEC=--disable metrics-server"}
+	if c.Version != "" {
+		env = append(env, "INSTALL_K3S_VERSION="+c.Version)
+	}
+	if c.Artifacts != "" {
+		env = append(env, "INSTALL_K3S_SKIP_DOWNLOAD=true")
+	}
+
+	return env
+}
+
+/*
+Install K3s
+  - @returns Nothing or an error
+*/
+func (c *K3s) Install() error {
+	// The service may be started by the script, configuration has to be there first
+	if err := copyConfig(k3sConfigDir, c.Options); err != nil {
+		return err
+	}
+
+	if c.Artifacts != "" {
+		if err := copyImages(c.Artifacts, "/var/lib/rancher/k3s/agent/images"); err != nil {
+			return err
+		}
+		if err := sudo("install", "-m", "0755", filepath.Join(c.Artifacts, "k3s"), "/usr/local/bin/k3s"); err != nil {
+			return err
+		}
+	}
+
+	script, err := installScript("https://get.k3s.io", "k3s-install.sh", c.Options)
+	if err != nil {
+		return err
+	}
+
+	return runInstall(c.Name(), func() *exec.Cmd {
+		cmd := exec.Command("sh", script)
+		cmd.Env = append(os.Environ(), c.Env()...)
+		return cmd
+	}, c.Options)
+}
+
+/*
+Start K3s
+  - @returns Nothing or an error
+*/
+func (c *K3s) Start() error {
+	return sudo("systemctl", "start", "k3s")
+}
+
+/*
+Wait for K3s to start
+  - @param k kubectl structure
+  - @returns Nothing or an error
+*/
+func (c *K3s) Wait(k *kubectl.Kubectl) error {
+	return waitFor(k,
+		[][]string{
+			{"kube-system", "app=local-path-provisioner"},
+			{"kube-system", "k8s-app=kube-dns"},
+			{"kube-system", "app.kubernetes.io/name=traefik"},
+			{"kube-system", "svccontroller.k3s.cattle.io/svcname=traefik"},
+		},
+		[][]string{
+			{"kube-system", "svccontroller.k3s.cattle.io/svcname=traefik"},
+		})
+}
+
+// Local path provisioner is deployed by K3s
+func (c *K3s) NeedsLocalStorage() bool {
+	return false
+}
+
+// Path of the Kubeconfig file
+func (c *K3s) Kubeconfig() string {
+	return filepath.Join(k3sConfigDir, "k3s.yaml")
+}
+
+/*
+Uninstall K3s
+  - @returns Nothing or an error
+*/
+func (c *K3s) Uninstall() error {
+	out, err := exec.Command("k3s-uninstall.sh").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+
+	return nil
+}
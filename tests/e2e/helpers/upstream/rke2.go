@@ -0,0 +1,128 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstream
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+)
+
+const rke2ConfigDir = "/etc/rancher/rke2"
+
+// RKE2 upstream cluster
+type RKE2 struct {
+	Options
+}
+
+// Name of the distribution
+func (c *RKE2) Name() string {
+	return "RKE2"
+}
+
+// Environment of the installation script
+func (c *RKE2) Env() []string {
+	var env []string
+	if c.Version != "" {
+		env = append(env, "INSTALL_RKE2_VERSION="+c.Version)
+	}
+	if c.Artifacts != "" {
+		env = append(env, "INSTALL_RKE2_ARTIFACT_PATH="+c.Artifacts)
+	}
+
+	return env
+}
+
+/*
+Install RKE2, the service is not started
+  - @returns Nothing or an error
+*/
+func (c *RKE2) Install() error {
+	if c.Artifacts != "" {
+		if err := copyImages(c.Artifacts, "/var/lib/rancher/rke2/agent/images"); err != nil {
+			return err
+		}
+	}
+
+	script, err := installScript("https://get.rke2.io", "rke2-install.sh", c.Options)
+	if err != nil {
+		return err
+	}
+
+	return runInstall(c.Name(), func() *exec.Cmd {
+		// Version can also be set in the environment of the tests
+		args := append([]string{"--preserve-env=INSTALL_RKE2_VERSION", "env"}, c.Env()...)
+		return exec.Command("sudo", append(args, "sh", script)...)
+	}, c.Options)
+}
+
+/*
+Configure and start RKE2
+  - @returns Nothing or an error
+*/
+func (c *RKE2) Start() error {
+	// Copy config files, this allows custom configuration for RKE2 installation
+	if err := copyConfig(rke2ConfigDir, c.Options); err != nil {
+		return err
+	}
+
+	// Activate and start RKE2
+	if err := sudo("systemctl", "enable", "--now", "rke2-server.service"); err != nil {
+		return err
+	}
+
+	// Be sure that any previous kubectl command has been removed before linking the new one
+	if err := sudo("rm", "-f", "/usr/local/bin/kubectl"); err != nil {
+		return err
+	}
+
+	return sudo("ln", "-s", "/var/lib/rancher/rke2/bin/kubectl", "/usr/local/bin/kubectl")
+}
+
+/*
+Wait for RKE2 to start
+  - @param k kubectl structure
+  - @returns Nothing or an error
+*/
+func (c *RKE2) Wait(k *kubectl.Kubectl) error {
+	return waitFor(k,
+		[][]string{
+			{"kube-system", "k8s-app=kube-dns"},
+			{"kube-system", "app.kubernetes.io/name=rke2-ingress-nginx"},
+		},
+		[][]string{
+			{"kube-system", "k8s-app=canal"},
+			{"kube-system", "app.kubernetes.io/instance=rke2-ingress-nginx"},
+		})
+}
+
+// RKE2 does not deploy any storage provisioner
+func (c *RKE2) NeedsLocalStorage() bool {
+	return true
+}
+
+// Path of the Kubeconfig file
+func (c *RKE2) Kubeconfig() string {
+	return filepath.Join(rke2ConfigDir, "rke2.yaml")
+}
+
+/*
+Uninstall RKE2
+  - @returns Nothing or an error
+*/
+func (c *RKE2) Uninstall() error {
+	return sudo("/usr/local/bin/rke2-uninstall.sh")
+}
@@ -0,0 +1,231 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstream
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher-sandbox/ele-testhelpers/rancher"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+)
+
+// Only a full version can be pinned, e.g. v1.30.5+k3s1
+var versionRegexp = regexp.MustCompile(`^v\d+\.\d+\.\d+\+(k3s|rke2r)\d+$`)
+
+// UpstreamCluster is the K8s distribution where Rancher Manager is installed
+type UpstreamCluster interface {
+	// Name of the distribution, as shown in the steps
+	Name() string
+	// Install the distribution, without starting it if possible
+	Install() error
+	// Configure and start the distribution
+	Start() error
+	// Wait for the system workloads to be started
+	Wait(k *kubectl.Kubectl) error
+	// True if no local storage provisioner is deployed by the distribution
+	NeedsLocalStorage() bool
+	// Path of the Kubeconfig file written by the distribution
+	Kubeconfig() string
+	// Remove the distribution and all its data
+	Uninstall() error
+}
+
+// Options are the installation options common to all distributions
+type Options struct {
+	// Version to install, the one set in the environment (or the latest) if empty
+	Version string
+	// Directory with install.sh, the binaries and the images for an air-gapped installation
+	Artifacts string
+	// Main configuration file, copied as config.yaml
+	Config string
+	// Configuration drop-ins, copied in config.yaml.d
+	DropIns []string
+	// Output of the installation script
+	Output io.Writer
+}
+
+/*
+Get the distribution of a K8s version
+  - @param version K8s version
+  - @returns rke2 if the version contains "rke2", k3s otherwise
+*/
+func Distribution(version string) string {
+	if strings.Contains(version, "rke2") {
+		return "rke2"
+	}
+
+	return "k3s"
+}
+
+/*
+Get the upstream cluster for a K8s version
+  - @param version K8s version, see Distribution
+  - @param o Installation options, the version is pinned if it is a full version
+  - @returns The upstream cluster
+*/
+func New(version string, o Options) UpstreamCluster {
+	if o.Version == "" && versionRegexp.MatchString(version) {
+		o.Version = version
+	}
+	if o.Artifacts != "" {
+		// Installation scripts are executed from another directory
+		if abs, err := filepath.Abs(o.Artifacts); err == nil {
+			o.Artifacts = abs
+		}
+	}
+	if o.Output == nil {
+		o.Output = io.Discard
+	}
+
+	if Distribution(version) == "rke2" {
+		return &RKE2{Options: o}
+	}
+
+	return &K3s{Options: o}
+}
+
+// Execute a command with root permissions
+func sudo(args ...string) error {
+	out, err := exec.Command("sudo", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(args, " "), err, out)
+	}
+
+	return nil
+}
+
+// Call a function until it succeeds or the timeout is reached
+func retry(timeout, interval time.Duration, f func() error) error {
+	deadline := time.Now().Add(tools.SetTimeout(timeout))
+	for {
+		err := f()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(interval)
+	}
+}
+
+/*
+Get the installation script
+  - @param url URL of the script, only used without air-gapped artifacts
+  - @param fileName Name of the downloaded script
+  - @param o Installation options
+  - @returns The path of the script or an error
+*/
+func installScript(url, fileName string, o Options) (string, error) {
+	if o.Artifacts != "" {
+		return filepath.Join(o.Artifacts, "install.sh"), nil
+	}
+
+	err := retry(2*time.Minute, 10*time.Second, func() error {
+		return tools.GetFileFromURL(url, fileName, true)
+	})
+
+	return fileName, err
+}
+
+/*
+Execute the installation script, with retries in case of (sporadic) failure
+  - @param name Name of the distribution, for the logs
+  - @param cmd Function creating the command, a command cannot be started twice
+  - @param o Installation options
+  - @returns Nothing or an error
+*/
+func runInstall(name string, cmd func() *exec.Cmd, o Options) error {
+	count := 1
+	return retry(2*time.Minute, 5*time.Second, func() error {
+		out, err := cmd().CombinedOutput()
+		fmt.Fprintf(o.Output, "%s installation loop %d:\n%s\n", name, count, out)
+		count++
+		return err
+	})
+}
+
+/*
+Copy the images of an air-gapped installation
+  - @param artifacts Directory of the artifacts
+  - @param dir Directory where the images are loaded from
+  - @returns Nothing or an error
+*/
+func copyImages(artifacts, dir string) error {
+	images, err := filepath.Glob(filepath.Join(artifacts, "*images*.tar*"))
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("no image archive in %s", artifacts)
+	}
+
+	if err := sudo("mkdir", "-p", dir); err != nil {
+		return err
+	}
+
+	return sudo(append(append([]string{"cp"}, images...), dir)...)
+}
+
+/*
+Copy the configuration files
+  - @param dir Configuration directory of the distribution
+  - @param o Installation options
+  - @returns Nothing or an error
+*/
+func copyConfig(dir string, o Options) error {
+	// NOTE: CopyFile cannot be used, as we need root permissions for these files
+	if err := sudo("mkdir", "-p", filepath.Join(dir, "config.yaml.d")); err != nil {
+		return err
+	}
+
+	if o.Config != "" {
+		if err := sudo("cp", o.Config, filepath.Join(dir, "config.yaml")); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range o.DropIns {
+		if err := sudo("cp", file, filepath.Join(dir, "config.yaml.d", filepath.Base(file))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Wait for system workloads
+  - @param k kubectl structure
+  - @param pods Pods to check, as namespace and label selector
+  - @param daemonSets DaemonSets to check, as namespace and label selector
+  - @returns Nothing or an error
+*/
+func waitFor(k *kubectl.Kubectl, pods, daemonSets [][]string) error {
+	err := retry(4*time.Minute, 30*time.Second, func() error {
+		return rancher.CheckPod(k, pods)
+	})
+	if err != nil {
+		return err
+	}
+
+	return retry(4*time.Minute, 30*time.Second, func() error {
+		return rancher.CheckDaemonSet(k, daemonSets)
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstream_test
+
+import (
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/elemental/tests/e2e/helpers/upstream"
+)
+
+var _ = Describe("Upstream tests", func() {
+	DescribeTable("Get the upstream cluster",
+		func(version, name, kubeconfig string, env []string) {
+			c := upstream.New(version, upstream.Options{})
+			Expect(c.Name()).To(Equal(name))
+			Expect(c.Kubeconfig()).To(Equal(kubeconfig))
+			Expect(upstream.Distribution(version)).To(Equal(strings.ToLower(name)))
+			Expect(c.NeedsLocalStorage()).To(Equal(name == "RKE2"))
+
+			switch c := c.(type) {
+			case *upstream.K3s:
+				Expect(c.Env()).To(Equal(env))
+			case *upstream.RKE2:
+				Expect(c.Env()).To(Equal(env))
+			}
+		},
+		Entry("pinned K3s", "v1.30.4+k3s1", "K3s", "/etc/rancher/k3s/k3s.yaml",
+			[]string{"INSTALL_K3S_EXEC=--disable metrics-server", "INSTALL_K3S_VERSION=v1.30.4+k3s1"}),
+		Entry("pinned RKE2", "v1.30.4+rke2r1", "RKE2", "/etc/rancher/rke2/rke2.yaml",
+			[]string{"INSTALL_RKE2_VERSION=v1.30.4+rke2r1"}),
+		Entry("latest RKE2", "rke2", "RKE2", "/etc/rancher/rke2/rke2.yaml", nil),
+		Entry("default", "", "K3s", "/etc/rancher/k3s/k3s.yaml",
+			[]string{"INSTALL_K3S_EXEC=--disable metrics-server"}),
+	)
+
+	It("Install from local artifacts", func() {
+		dir := GinkgoT().TempDir()
+
+		k3s := upstream.New("v1.30.4+k3s1", upstream.Options{Artifacts: dir}).(*upstream.K3s)
+		Expect(k3s.Env()).To(ContainElement("INSTALL_K3S_SKIP_DOWNLOAD=true"))
+
+		// Version given in the options is not overwritten
+		rke2 := upstream.New("v1.30.4+rke2r1", upstream.Options{Version: "v1.31.1+rke2r1", Artifacts: dir}).(*upstream.RKE2)
+		Expect(rke2.Env()).To(Equal([]string{"INSTALL_RKE2_VERSION=v1.31.1+rke2r1", "INSTALL_RKE2_ARTIFACT_PATH=" + dir}))
+
+		// Scripts are not executed from the current directory
+		rke2 = upstream.New("rke2", upstream.Options{Artifacts: "artifacts"}).(*upstream.RKE2)
+		Expect(filepath.IsAbs(rke2.Artifacts)).To(BeTrue())
+		Expect(rke2.Artifacts).To(HaveSuffix("/artifacts"))
+	})
+})
@@ -17,7 +17,6 @@ package e2e_test
 import (
 	"os"
 	"os/exec"
-	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -54,63 +53,49 @@ var _ = Describe("E2E - Install Rancher Manager", Label("install"), func() {
 	// Define local Kubeconfig file
 	localKubeconfig := os.Getenv("HOME") + "/.kube/config"
 
-	It("Install upstream K8s cluster", func() {
-		if strings.Contains(k8sUpstreamVersion, "rke2") {
-			// Report to Qase
+	It("Install upstream K8s cluster", Label("qase-59", "qase-60"), func() {
+		// Report to Qase
+		switch upstreamCluster.Name() {
+		case "RKE2":
 			testCaseID = 60
+		default:
+			testCaseID = 59
+		}
+
+		By("Installing "+upstreamCluster.Name(), func() {
+			err := upstreamCluster.Install()
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Installing RKE2", func() {
-				InstallRKE2()
+		if clusterType == "hardened" {
+			By("Configuring hardened cluster", func() {
+				err := exec.Command("sudo", installHardenedScript).Run()
+				Expect(err).To(Not(HaveOccurred()))
 			})
+		}
 
-			if clusterType == "hardened" {
-				By("Configuring hardened cluster", func() {
-					err := exec.Command("sudo", installHardenedScript).Run()
-					Expect(err).To(Not(HaveOccurred()))
-				})
-			}
+		By("Starting "+upstreamCluster.Name(), func() {
+			err := upstreamCluster.Start()
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			By("Starting RKE2", func() {
-				StartRKE2()
-			})
+		By("Waiting for "+upstreamCluster.Name()+" to be started", func() {
+			err := os.Setenv("KUBECONFIG", upstreamCluster.Kubeconfig())
+			Expect(err).To(Not(HaveOccurred()))
 
-			By("Waiting for RKE2 to be started", func() {
-				WaitForRKE2(k)
-			})
+			err = upstreamCluster.Wait(k)
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
+		if upstreamCluster.NeedsLocalStorage() {
 			By("Installing local-path-provisionner", func() {
 				InstallLocalStorage(k)
 			})
-		} else {
-			// Report to Qase
-			testCaseID = 59
-
-			By("Installing K3s", func() {
-				InstallK3s()
-			})
-
-			if clusterType == "hardened" {
-				By("Configuring hardened cluster", func() {
-					err := exec.Command("sudo", installHardenedScript).Run()
-					Expect(err).To(Not(HaveOccurred()))
-				})
-			}
-
-			By("Starting K3s", func() {
-				StartK3s()
-			})
-
-			By("Waiting for K3s to be started", func() {
-				WaitForK3s(k)
-			})
 		}
 
 		By("Configuring Kubeconfig file", func() {
-			// Copy K3s file in ~/.kube/config
-			// NOTE: don't check for error, as it will happen anyway (only K3s or RKE2 is installed at a time)
-			file, _ := exec.Command("bash", "-c", "ls /etc/rancher/{k3s,rke2}/{k3s,rke2}.yaml").Output()
-			Expect(file).To(Not(BeEmpty()))
-			err := tools.CopyFile(strings.Trim(string(file), "\n"), localKubeconfig)
+			// Copy upstream cluster file in ~/.kube/config
+			err := tools.CopyFile(upstreamCluster.Kubeconfig(), localKubeconfig)
 			Expect(err).To(Not(HaveOccurred()))
 
 			err = os.Setenv("KUBECONFIG", localKubeconfig)
@@ -130,10 +115,7 @@ var _ = Describe("E2E - Install Rancher Manager", Label("install"), func() {
 		}
 	})
 
-	It("Install Rancher Manager", func() {
-		// Report to Qase
-		testCaseID = 61
-
+	It("Install Rancher Manager", Label("qase-61"), func() {
 		// Inject secret for Private CA
 		if caType == "private" {
 			// The namespace must exist before adding secret
@@ -217,15 +199,16 @@ var _ = Describe("E2E - Install Rancher Manager", Label("install"), func() {
 	})
 
 	// Deploy operator in CLI test
-	It("Install Elemental Operator if needed", func() {
-		if operatorInstallType == "cli" {
-			By("Installing Operator with CLI", func() {
-				// Report to Qase
-				testCaseID = 62
-
-				installOrder := []string{"elemental-operator-crds", "elemental-operator"}
-				InstallElementalOperator(k, installOrder, operatorRepo)
-			})
+	It("Install Elemental Operator if needed", Label("qase-62"), func() {
+		if operatorInstallType != "cli" {
+			// Nothing done, so nothing to report to Qase
+			testCaseID = -1
+			return
 		}
+
+		By("Installing Operator with CLI", func() {
+			installOrder := []string{"elemental-operator-crds", "elemental-operator"}
+			InstallElementalOperator(k, installOrder, operatorRepo)
+		})
 	})
 })
@@ -15,14 +15,19 @@ limitations under the License.
 package e2e_test
 
 import (
+	"bytes"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/bundle"
+	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/squid"
 )
 
 func checkRC(err error) {
@@ -42,41 +47,70 @@ var _ = Describe("E2E - Getting logs node", Label("logs"), func() {
 		Verb []string
 	}
 
-	It("Get the upstream cluster logs", func() {
-		// Report to Qase
-		testCaseID = 69
+	It("Get the upstream cluster logs", Label("qase-69"), func() {
+		_ = os.Mkdir("logs", 0755)
+		_ = os.Chdir("logs")
 
-		By("Downloading and executing tools to generate logs", func() {
-			elemental := binary{
-				elementalSupport,
-				"elemental-support",
-			}
+		By("Collecting support bundle", func() {
+			b := bundle.New()
+
+			checkRC(b.CollectElementalResources())
+			checkRC(b.CollectPodLogs(bundle.DefaultNamespaces...))
+			checkRC(b.CollectEvents())
+			checkRC(b.CollectHelmReleases())
+
+			// Nodes may not be reachable, e.g. if the test failed during installation
+			inventories, err := elemental.ListMachineInventories(clusterNS, "")
+			checkRC(err)
+			for _, mi := range inventories {
+				if mi.IP == "" {
+					continue
+				}
 
-			logCollector := binary{
-				rancherLogCollector,
-				"rancher2_logs_collector.sh",
+				cl := &tools.Client{
+					Host:     mi.IP + ":22",
+					Username: userName,
+					Password: userPassword,
+				}
+				checkRC(b.CollectNode(mi.Name, cl))
 			}
 
-			_ = os.Mkdir("logs", 0755)
-			_ = os.Chdir("logs")
-			myDir, _ := os.Getwd()
+			err = b.WriteFile("support-bundle.tar.gz")
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-			for _, b := range []binary{elemental, logCollector} {
-				Eventually(func() error {
-					return exec.Command("curl", "-L", b.Url, "-o", b.Name).Run()
-				}, tools.SetTimeout(1*time.Minute), 5*time.Second).Should(Not(HaveOccurred()))
+		// External tools are only used if available
+		if elementalSupport != "" && rancherLogCollector != "" {
+			By("Downloading and executing tools to generate logs", func() {
+				support := binary{
+					elementalSupport,
+					"elemental-support",
+				}
 
-				err := exec.Command("chmod", "+x", b.Name).Run()
-				checkRC(err)
-				if b.Name == "elemental-support" {
-					err := exec.Command(myDir + "/" + b.Name).Run()
-					checkRC(err)
-				} else {
-					err := exec.Command("sudo", myDir+"/"+b.Name, "-d", "../logs").Run()
+				logCollector := binary{
+					rancherLogCollector,
+					"rancher2_logs_collector.sh",
+				}
+
+				myDir, _ := os.Getwd()
+
+				for _, b := range []binary{support, logCollector} {
+					Eventually(func() error {
+						return exec.Command("curl", "-L", b.Url, "-o", b.Name).Run()
+					}, tools.SetTimeout(1*time.Minute), 5*time.Second).Should(Not(HaveOccurred()))
+
+					err := exec.Command("chmod", "+x", b.Name).Run()
 					checkRC(err)
+					if b.Name == "elemental-support" {
+						err := exec.Command(myDir + "/" + b.Name).Run()
+						checkRC(err)
+					} else {
+						err := exec.Command("sudo", myDir+"/"+b.Name, "-d", "../logs").Run()
+						checkRC(err)
+					}
 				}
-			}
-		})
+			})
+		}
 
 		By("Collecting additionals logs with kubectl commands", func() {
 			Bundles := getResourceLog{
@@ -102,6 +136,29 @@ var _ = Describe("E2E - Getting logs node", Label("logs"), func() {
 				err = os.WriteFile("squid.log", []byte(out), os.ModePerm)
 				checkRC(err)
 				Expect(out).Should(MatchRegexp("TCP_TUNNEL/200.*CONNECT.*(docker.io|rancher)"))
+
+				entries, err := squid.Parse(bytes.NewReader(out))
+				Expect(err).To(Not(HaveOccurred()))
+				err = os.WriteFile("squid-requests.log", []byte(squid.Group(entries).String()), os.ModePerm)
+				checkRC(err)
+
+				// We are in the logs directory
+				policies, err := squid.LoadPolicies(filepath.Join("..", proxyPolicyYaml))
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(policies).To(HaveKey(proxy))
+
+				// Nodes are identified by their registration IP
+				inventories, err := elemental.ListMachineInventories(clusterNS, "")
+				Expect(err).To(Not(HaveOccurred()))
+				var nodes []string
+				for _, mi := range inventories {
+					if mi.IP != "" {
+						nodes = append(nodes, mi.IP)
+					}
+				}
+
+				err = policies[proxy].Check(entries, nodes)
+				Expect(err).To(Not(HaveOccurred()))
 			})
 		}
 	})
@@ -17,7 +17,6 @@ package e2e_test
 import (
 	"os"
 	"os/exec"
-	"strconv"
 	"sync"
 	"time"
 
@@ -27,6 +26,7 @@ import (
 	"github.com/rancher-sandbox/ele-testhelpers/rancher"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
 	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/layout"
 )
 
 var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
@@ -66,10 +66,7 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 		}
 	})
 
-	It("Configure Libvirt", func() {
-		// Report to Qase
-		testCaseID = 68
-
+	It("Configure Libvirt", Label("qase-68"), func() {
 		By("Starting default network", func() {
 			// Don't check return code, as the default network could be already removed
 			for _, c := range []string{"net-destroy", "net-undefine"} {
@@ -83,10 +80,7 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 		})
 	})
 
-	It("Configure and create ISO image", func() {
-		// Report to Qase
-		testCaseID = 38
-
+	It("Configure and create ISO image", Label("qase-38"), func() {
 		By("Adding MachineRegistration", func() {
 			// Set temporary file
 			registrationTmp, err := tools.CreateTemp("machineRegistration")
@@ -129,12 +123,11 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 			WaitForOSVersion(clusterNS)
 
 			// Get OSVersion name
-			OSVersion, err := exec.Command(getOSScript, os2Test, "true").Output()
-			Expect(err).To(Not(HaveOccurred()))
+			OSVersion := GetOSVersionName(os2Test, elemental.OSVersionISO)
 			Expect(OSVersion).To(Not(BeEmpty()))
 
 			// Extract container image URL
-			baseImageURL, err := elemental.GetImageURI(clusterNS, string(OSVersion))
+			baseImageURL, err := elemental.GetImageURI(clusterNS, OSVersion)
 			Expect(err).To(Not(HaveOccurred()))
 			Expect(baseImageURL).To(Not(BeEmpty()))
 
@@ -167,20 +160,29 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 		})
 	})
 
-	It("Downloading ISO built by SeedImage", func() {
-		// Report to Qase
-		testCaseID = 38
-
+	It("Downloading ISO built by SeedImage", Label("qase-39"), func() {
 		DownloadBuiltISO(clusterNS, seedImageName, "../../elemental-multi.iso")
 	})
 
-	It("Create clusters and deploy nodes", func() {
-		// Report to Qase
-		testCaseID = 9
+	It("Create clusters and deploy nodes", Label("qase-9"), func() {
+		// Identical clusters are created if no layout is provided
+		clusters := layout.Default(numberOfClusters, clusterName, k8sDownstreamVersion)
+		if clusterLayout != "" {
+			var err error
+			clusters, err = layout.Load(clusterLayout, k8sDownstreamVersion)
+			Expect(err).To(Not(HaveOccurred()))
+		}
+		GinkgoWriter.Printf("Deploying %d cluster(s) with %d node(s)\n", len(clusters.Clusters), clusters.Nodes())
+
+		// Cluster and pool of each node
+		var assignments []layout.Assignment
 
 		// Loop on all clusters to create
-		for clusterIndex := 1; clusterIndex <= numberOfClusters; clusterIndex++ {
-			createdClusterName := clusterName + "-" + strconv.Itoa(clusterIndex)
+		for _, c := range clusters.Clusters {
+			createdClusterName := c.Name
+
+			pools, err := c.RenderPools(6)
+			Expect(err).To(Not(HaveOccurred()))
 
 			// Patterns to replace
 			addPatterns := []YamlPattern{
@@ -190,12 +192,16 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 				},
 				{
 					key:   "%K8S_VERSION%",
-					value: k8sDownstreamVersion,
+					value: c.Version,
+				},
+				{
+					key:   "%MACHINE_POOLS%",
+					value: pools,
 				},
 			}
 			patterns := append(basePatterns, addPatterns...)
 
-			By("Creating cluster "+createdClusterName, func() {
+			By("Creating cluster "+createdClusterName+" with "+c.Version, func() {
 				// Set temporary file
 				clusterTmp, err := tools.CreateTemp(createdClusterName)
 				Expect(err).To(Not(HaveOccurred()))
@@ -219,126 +225,207 @@ var _ = Describe("E2E - Bootstrapping nodes", Label("multi-cluster"), func() {
 				CheckCreatedCluster(clusterNS, createdClusterName)
 			})
 
-			By("Creating cluster selector for cluster "+createdClusterName, func() {
-				// Set temporary file
-				selectorTmp, err := tools.CreateTemp("selector")
-				Expect(err).To(Not(HaveOccurred()))
-				defer os.Remove(selectorTmp)
+			for _, pool := range c.Pools {
+				selectorName := c.SelectorName(pool)
 
-				// Save original file as it may have to be modified twice
-				err = tools.CopyFile(selectorYaml, selectorTmp)
-				Expect(err).To(Not(HaveOccurred()))
+				By("Creating cluster selector "+selectorName, func() {
+					// Set temporary file
+					selectorTmp, err := tools.CreateTemp("selector")
+					Expect(err).To(Not(HaveOccurred()))
+					defer os.Remove(selectorTmp)
 
-				// Create Yaml file
-				for _, p := range patterns {
-					err := tools.Sed(p.key, p.value, selectorTmp)
+					// Save original file as it may have to be modified twice
+					err = tools.CopyFile(selectorYaml, selectorTmp)
 					Expect(err).To(Not(HaveOccurred()))
-				}
 
-				// Apply to k8s
-				err = kubectl.Apply(clusterNS, selectorTmp)
-				Expect(err).To(Not(HaveOccurred()))
+					selectorPatterns := []YamlPattern{
+						{
+							key:   "%SELECTOR_NAME%",
+							value: selectorName,
+						},
+						{
+							key:   "%SELECTOR_LABELS%",
+							value: layout.RenderLabels(c.SelectorLabels(pool), 10),
+						},
+					}
+
+					// Create Yaml file
+					for _, p := range append(patterns, selectorPatterns...) {
+						err := tools.Sed(p.key, p.value, selectorTmp)
+						Expect(err).To(Not(HaveOccurred()))
+					}
 
-				// Check that the selector template is correctly created
-				CheckCreatedSelectorTemplate(clusterNS, "selector-"+createdClusterName)
-			})
+					// Apply to k8s
+					err = kubectl.Apply(clusterNS, selectorTmp)
+					Expect(err).To(Not(HaveOccurred()))
+
+					// Check that the selector template is correctly created
+					CheckCreatedSelectorTemplate(clusterNS, selectorName)
+				})
+			}
+		}
 
-			// Loop on node provisionning
-			for nodeIndex := 1; nodeIndex <= 3; nodeIndex++ {
-				// Incremente global node index
-				globalNodeID++
+		// Hostnames and MachineInventories of all nodes
+		var (
+			hostNames   []string
+			inventories = map[string]elemental.MachineInventory{}
+		)
+
+		// VMs are created with the CPU cores of their hardware class, the MachineRegistration
+		// reports it as a MachineInventory label used to place them
+		var classes []string
+		if clusters.Placement.Strategy == layout.Hardware {
+			classes = clusters.HardwareClasses()
+		}
 
-				// Set node hostname
-				hostName := elemental.SetHostname(vmNameRoot+"-"+createdClusterName, nodeIndex)
-				Expect(hostName).To(Not(BeEmpty()))
+		// Loop on node provisionning, nodes are not linked to a cluster yet
+		for i := 0; i < clusters.Nodes(); i++ {
+			// Incremente global node index
+			globalNodeID++
 
-				// Add node in network configuration
-				err := rancher.AddNode(netDefaultFileName, hostName, globalNodeID)
-				Expect(err).To(Not(HaveOccurred()))
+			// Set node hostname
+			hostName := elemental.SetHostname(vmNameRoot+"-multi", globalNodeID)
+			Expect(hostName).To(Not(BeEmpty()))
+			hostNames = append(hostNames, hostName)
 
-				// Get generated MAC address
-				_, macAdrs := GetNodeInfo(hostName)
-				Expect(macAdrs).To(Not(BeEmpty()))
+			// Add node in network configuration
+			err := rancher.AddNode(netDefaultFileName, hostName, globalNodeID)
+			Expect(err).To(Not(HaveOccurred()))
 
-				wg.Add(1)
-				go func(s, h, m string) {
-					defer wg.Done()
-					defer GinkgoRecover()
+			// Get generated MAC address
+			_, macAdrs := GetNodeInfo(hostName)
+			Expect(macAdrs).To(Not(BeEmpty()))
 
-					By("Installing node "+h+" on cluster "+createdClusterName, func() {
-						// Execute node deployment in parallel
-						err := exec.Command(s, h, m).Run()
-						Expect(err).To(Not(HaveOccurred()))
-					})
-				}(installVMScript, hostName, macAdrs)
+			// Set node hardware
+			cmd := exec.Command(installVMScript, hostName, macAdrs)
+			if classes != nil {
+				cmd.Env = append(os.Environ(), "VM_CPU="+classes[i])
 			}
 
-			// Wait for all parallel jobs
-			wg.Wait()
+			wg.Add(1)
+			go func(h string, c *exec.Cmd) {
+				defer wg.Done()
+				defer GinkgoRecover()
 
-			// Add needed label on provisionned nodes
-			for nodeIndex := 1; nodeIndex <= 3; nodeIndex++ {
-				// Set node hostname
-				hostName := elemental.SetHostname(vmNameRoot+"-"+createdClusterName, nodeIndex)
-				Expect(hostName).To(Not(BeEmpty()))
+				By("Installing node "+h, func() {
+					// Execute node deployment in parallel
+					err := c.Run()
+					Expect(err).To(Not(HaveOccurred()))
+				})
+			}(hostName, cmd)
+		}
+
+		// Wait for all parallel jobs
+		wg.Wait()
 
-				// Get node's IP
+		By("Placing nodes with "+clusters.Placement.Strategy+" strategy", func() {
+			list, err := elemental.ListMachineInventories(clusterNS, "")
+			Expect(err).To(Not(HaveOccurred()))
+
+			var nodes []layout.Node
+			for _, hostName := range hostNames {
+				// Get MachineInventory from node's IP
 				ip := GetNodeIP(hostName)
+				for _, mi := range list {
+					if mi.IP == ip {
+						inventories[hostName] = mi
+					}
+				}
+				Expect(inventories).To(HaveKey(hostName), "No MachineInventory found for "+hostName)
 
-				// Get MachineInventory name
-				nodeName, err := kubectl.RunWithoutErr("get", "MachineInventory",
-					"--namespace", clusterNS,
-					"-o", "jsonpath={.items[?(@.metadata.annotations.elemental\\.cattle\\.io/registration-ip==\""+ip+"\")].metadata.name}")
-				Expect(err).To(Not(HaveOccurred()))
+				// Hardware class is set by the MachineRegistration
+				class := inventories[hostName].Labels[clusters.Placement.HardwareLabel]
+				if clusters.Placement.Strategy == layout.Hardware {
+					Expect(class).To(Not(BeEmpty()), "No "+clusters.Placement.HardwareLabel+" label on MachineInventory of "+hostName)
+				}
+				nodes = append(nodes, layout.Node{Name: hostName, Class: class})
+			}
 
-				// Add label
-				elemental.SetMachineInventoryLabel(clusterNS, nodeName, "clusterName", createdClusterName)
+			assignments, err = clusters.Place(nodes)
+			Expect(err).To(Not(HaveOccurred()))
+		})
 
-				// Get node information
-				client, _ := GetNodeInfo(hostName)
+		// Add needed labels on provisionned nodes
+		for _, a := range assignments {
+			c, pool, _ := clusters.Lookup(a)
+			nodeName := inventories[a.Node].Name
 
-				// Restart node(s)
-				wg.Add(1)
-				go func(h string, cl *tools.Client) {
-					defer wg.Done()
-					defer GinkgoRecover()
+			By("Assigning "+a.Node+" to pool "+a.Pool+" of cluster "+a.Cluster, func() {
+				for key, value := range c.SelectorLabels(pool) {
+					err := elemental.SetMachineInventoryLabel(clusterNS, nodeName, key, value)
+					Expect(err).To(Not(HaveOccurred()))
+				}
+			})
 
-					By("Restarting "+h+" to add it in cluster "+createdClusterName, func() {
-						err := exec.Command("sudo", "virsh", "start", h).Run()
-						Expect(err).To(Not(HaveOccurred()))
-					})
+			// Get node information
+			client, _ := GetNodeInfo(a.Node)
 
-					By("Checking "+h+" SSH connection", func() {
-						CheckSSH(cl)
-					})
-				}(hostName, client)
-			}
+			// Restart node(s)
+			wg.Add(1)
+			go func(h string, cl *tools.Client) {
+				defer wg.Done()
+				defer GinkgoRecover()
 
-			// Wait for all parallel jobs
-			wg.Wait()
+				By("Restarting "+h+" to add it in cluster "+a.Cluster, func() {
+					err := exec.Command("sudo", "virsh", "start", h).Run()
+					Expect(err).To(Not(HaveOccurred()))
+				})
 
-			By("Waiting for cluster "+createdClusterName+" to be Active", func() {
-				WaitCluster(clusterNS, createdClusterName)
-			})
+				By("Checking "+h+" SSH connection", func() {
+					CheckSSH(cl)
+				})
+			}(a.Node, client)
 		}
 
-		// Loop on all clusters to check
-		for clusterIndex := 1; clusterIndex <= numberOfClusters; clusterIndex++ {
-			createdClusterName := clusterName + "-" + strconv.Itoa(clusterIndex)
+		// Wait for all parallel jobs
+		wg.Wait()
 
-			// Do a final check on all created clusters to validate them
-			// NOTE: do it in parallel to speed-up the checking process
+		// Do a final check on all created clusters to validate them
+		// NOTE: do it in parallel to speed-up the checking process
+		for _, c := range clusters.Clusters {
 			wg.Add(1)
-			go func(ns, c string) {
+			go func(ns string, c layout.Cluster) {
 				defer wg.Done()
 				defer GinkgoRecover()
-				By("Waiting for cluster "+c+" to be Active", func() {
-					WaitCluster(ns, c)
+
+				By("Waiting for cluster "+c.Name+" to be Active", func() {
+					WaitCluster(ns, c.Name)
 				})
-			}(clusterNS, createdClusterName)
 
-			// Wait for all parallel jobs
-			wg.Wait()
+				By("Checking cluster "+c.Name+" version", func() {
+					Eventually(func() string {
+						version, _ := elemental.GetClusterVersion(ns, c.Name)
+						return version
+					}, tools.SetTimeout(5*time.Minute), 10*time.Second).Should(Equal(c.Version))
+				})
+
+				By("Checking cluster "+c.Name+" nodes", func() {
+					machines, err := elemental.ListClusterMachines(ns, c.Name)
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(machines).To(HaveLen(c.Nodes()))
+				})
+			}(clusterNS, c)
 		}
+
+		// Wait for all parallel jobs
+		wg.Wait()
+
+		By("Checking that each node landed in its cluster", func() {
+			for _, a := range assignments {
+				machine, err := elemental.GetInternalMachine(clusterNS, inventories[a.Node].Name)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(machine).To(Not(BeEmpty()), "No Machine found for "+a.Node)
+
+				cluster, err := elemental.GetMachineCluster(clusterNS, machine)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(cluster).To(Equal(a.Cluster), a.Node+" is not in the expected cluster")
+
+				// Cluster should match the hardware of the node
+				if clusters.Placement.Strategy == layout.Hardware {
+					c, _, _ := clusters.Lookup(a)
+					Expect(inventories[a.Node].Labels).To(HaveKeyWithValue(clusters.Placement.HardwareLabel, c.HardwareClass))
+				}
+			}
+		})
 	})
 })
@@ -15,64 +15,466 @@ limitations under the License.
 package e2e_test
 
 import (
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/helpers"
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/bootmode"
 	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+	"github.com/rancher/elemental/tests/e2e/helpers/persistence"
 )
 
-var _ = Describe("E2E - Test the reset feature", Label("reset"), func() {
-	It("Reset one node in the cluster", func() {
-		// Report to Qase
-		testCaseID = 54
+// Node to reset and its state before the reset
+type resetNode struct {
+	name      string
+	client    *tools.Client
+	labels    map[string]string
+	tpmHash   string
+	machine   string
+	oemBefore []string
+	checker   *persistence.Checker
+}
+
+/*
+Set SSH client from a MachineInventory
+  - @param mi MachineInventory
+  - @returns Client to access the node through SSH
+*/
+func clientFromInventory(mi elemental.MachineInventory) *tools.Client {
+	Expect(mi.IP).To(Not(BeEmpty()))
+
+	return &tools.Client{
+		Host:     mi.IP + ":22",
+		Username: userName,
+		Password: userPassword,
+	}
+}
+
+/*
+Get the state of a node before the reset
+  - @param mi MachineInventory of the node
+  - @returns The node to reset
+*/
+func prepareReset(mi elemental.MachineInventory) *resetNode {
+	n := &resetNode{
+		name:    mi.Name,
+		client:  clientFromInventory(mi),
+		labels:  mi.Labels,
+		checker: persistence.New(strconv.FormatInt(time.Now().UnixNano(), 10)),
+	}
+
+	By("Getting registration identity of "+n.name+" before the reset", func() {
+		// Should be kept after the reset
+		var err error
+		n.tpmHash, err = elemental.GetMachineInventoryTPMHash(clusterNS, n.name)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(n.tpmHash).To(Not(BeEmpty()))
+
+		n.machine, err = elemental.GetInternalMachine(clusterNS, n.name)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(n.machine).To(Not(BeEmpty()))
+	})
+
+	By("Getting OEM files of "+n.name+" before the reset", func() {
+		// The same files should be created after the reset
+		var err error
+		CheckSSH(n.client)
+		n.oemBefore, err = elemental.ListOEMFiles(n.client)
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	By("Writing persistence markers on "+n.name+" before the reset", func() {
+		// Reset should wipe persistent data
+		Expect(n.checker.Write(n.client)).To(Succeed())
+	})
+
+	return n
+}
 
-		// Get the machine inventory name list
-		machineInventory, err := kubectl.RunWithoutErr("get", "MachineInventory",
+/*
+Trigger the reset of a node
+  - @param n Node to reset
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func triggerReset(n *resetNode) {
+	By("Configuring reset at MachineInventory level for "+n.name, func() {
+		_, err := kubectl.RunWithoutErr("patch", "MachineInventory", n.name,
+			"--namespace", clusterNS, "--type", "merge",
+			"--patch-file", resetMachineInv)
+		Expect(err).To(Not(HaveOccurred()))
+	})
+
+	By("Deleting and removing "+n.name+" from the cluster", func() {
+		_, err := kubectl.RunWithoutErr("delete", "machines", n.machine,
+			"--namespace", clusterNS)
+		Expect(err).To(Not(HaveOccurred()))
+	})
+}
+
+/*
+Wait for a MachineInventory to be deleted or created
+  - @param n Node to check
+  - @param deleted Wait for deletion if true, for creation otherwise
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func waitResetInventory(n *resetNode, deleted bool) {
+	getNames := func() string {
+		out, _ := kubectl.RunWithoutErr("get", "MachineInventory",
 			"--namespace", clusterNS,
-			"-o", "jsonpath='{.items[*].metadata.name}'")
+			"-o", "jsonpath={.items[*].metadata.name}")
+		return out
+	}
+
+	if deleted {
+		By("Checking that MachineInventory "+n.name+" is deleted", func() {
+			Eventually(getNames, tools.SetTimeout(10*time.Minute), 5*time.Second).ShouldNot(ContainSubstring(n.name))
+		})
+	} else {
+		By("Checking that MachineInventory "+n.name+" is back after the reset", func() {
+			Eventually(getNames, tools.SetTimeout(8*time.Minute), 5*time.Second).Should(ContainSubstring(n.name))
+		})
+	}
+}
+
+/*
+Check the state of a node after the reset
+  - @param n Node to check
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func checkResetNode(n *resetNode) {
+	By("Checking that "+n.name+" is registered with the same identity", func() {
+		tpmHashAfter, err := elemental.GetMachineInventoryTPMHash(clusterNS, n.name)
 		Expect(err).To(Not(HaveOccurred()))
-		firstMachineInventory := strings.Split(machineInventory, " ")[1]
+		Expect(tpmHashAfter).To(Equal(n.tpmHash))
+	})
 
-		By("Configuring reset at MachineInventory level", func() {
-			// Patch the first machine inventory to enable reset
-			_, err = kubectl.RunWithoutErr("patch", "MachineInventory", firstMachineInventory,
-				"--namespace", clusterNS, "--type", "merge",
-				"--patch-file", resetMachineInv)
-			Expect(err).To(Not(HaveOccurred()))
+	By("Checking that "+n.name+" rejoined the cluster with a new Machine", func() {
+		Eventually(func() string {
+			machine, _ := elemental.GetInternalMachine(clusterNS, n.name)
+			return machine
+		}, tools.SetTimeout(5*time.Minute), 10*time.Second).Should(And(Not(BeEmpty()), Not(Equal(n.machine))))
+	})
+
+	By("Checking snapshots on "+n.name+" after the reset", func() {
+		CheckSSH(n.client)
+		CheckSnapshotter(n.client)
+
+		// Reset creates a new system with only one snapshot
+		snapshots := GetSnapshots(n.client)
+		Expect(snapshots).To(HaveLen(1))
+		Expect(snapshots[0].Active).To(BeTrue())
+	})
+
+	By("Checking persistence markers on "+n.name+" after the reset", func() {
+		Expect(n.checker.Verify(n.client, persistence.Reset)).To(Succeed())
+	})
+
+	By("Checking OEM files on "+n.name+" after the reset", func() {
+		oemAfter, err := elemental.ListOEMFiles(n.client)
+		Expect(err).To(Not(HaveOccurred()))
+
+		// Only the files created by the installation/registration should be there
+		diff := misc.DiffLists(n.oemBefore, oemAfter)
+		GinkgoWriter.Printf("OEM files diff on %s:\n%s\n", n.name, diff)
+		Expect(diff.Added).To(BeEmpty(), "Unexpected files in /oem")
+		Expect(diff.Removed).To(BeEmpty(), "Missing files in /oem")
+	})
+}
+
+/*
+Observe the reset executed in recovery mode, this is best effort as the node could be too fast
+  - @param cl Client (node) informations
+  - @returns Time when recovery mode has been seen and the reset service times
+*/
+func observeResetService(cl *tools.Client) (time.Time, elemental.ServiceTimes) {
+	var seen time.Time
+	var times elemental.ServiceTimes
+
+	// Wait for the node to be in recovery mode
+	err := helpers.PollImmediate(5*time.Second, tools.SetTimeout(10*time.Minute), func() (bool, error) {
+		if mode, err := bootmode.Current(cl); err != nil || mode != bootmode.Recovery {
+			return false, nil
+		}
+		seen = time.Now()
+		return true, nil
+	})
+	if err != nil {
+		GinkgoWriter.Printf("Recovery mode not observed on %s: %v\n", cl.Host, err)
+		return seen, times
+	}
+
+	// Get the service times until the node leaves recovery mode
+	_ = helpers.PollImmediate(5*time.Second, tools.SetTimeout(10*time.Minute), func() (bool, error) {
+		if mode, err := bootmode.Current(cl); err != nil || mode != bootmode.Recovery {
+			return true, nil
+		}
+		if t, err := elemental.GetServiceTimes(cl, elemental.ResetService); err == nil {
+			times = t
+		}
+		return false, nil
+	})
+
+	return seen, times
+}
+
+/*
+Check periodically that the Kubernetes API answers on at least one master node
+  - @param clients Master nodes to use
+  - @returns Function to stop the check, it returns the number of failed checks
+*/
+func watchQuorum(clients []*tools.Client) func() int32 {
+	var failures atomic.Int32
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		defer GinkgoRecover()
+
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ready := false
+				for _, cl := range clients {
+					if ok, _ := elemental.IsAPIReady(cl); ok {
+						ready = true
+						break
+					}
+				}
+				if !ready {
+					GinkgoWriter.Printf("Kubernetes API not available on any master node!\n")
+					failures.Add(1)
+				}
+			}
+		}
+	}()
+
+	return func() int32 {
+		close(done)
+		<-stopped
+		return failures.Load()
+	}
+}
+
+var _ = Describe("E2E - Test the reset feature", Label("reset"), func() {
+	It("Reset one node in the cluster", Label("qase-54"), func() {
+		// Get the machine inventory list
+		inventories, err := elemental.ListMachineInventories(clusterNS, "")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(len(inventories)).To(BeNumerically(">", 1))
+
+		n := prepareReset(inventories[1])
+
+		// Used to record the duration of each reset phase
+		startTime := time.Now()
+		phaseTime := startTime
+		recordPhase := func(name string, end time.Time) {
+			if end.IsZero() {
+				return
+			}
+			AddReportEntry("Reset phase: "+name, end.Sub(phaseTime).Round(time.Second).String())
+			phaseTime = end
+		}
+
+		triggerReset(n)
+
+		waitResetInventory(n, true)
+		recordPhase("MachineInventory deletion", time.Now())
+
+		By("Observing "+elemental.ResetService+" in recovery mode", func() {
+			seen, times := observeResetService(n.client)
+			recordPhase("reboot in recovery", seen)
+
+			GinkgoWriter.Printf("%s on %s: %+v\n", elemental.ResetService, n.name, times)
+			if !times.Start.IsZero() && !times.Exit.IsZero() {
+				AddReportEntry("Reset phase: "+elemental.ResetService, times.Exit.Sub(times.Start).Round(time.Second).String())
+			}
 		})
 
-		By("Deleting and removing the node from the cluster", func() {
-			machineToRemove, err := elemental.GetInternalMachine(clusterNS, firstMachineInventory)
-			Expect(err).To(Not(HaveOccurred()))
-			_, err = kubectl.RunWithoutErr("delete", "machines", machineToRemove,
-				"--namespace", clusterNS)
-			Expect(err).To(Not(HaveOccurred()))
+		waitResetInventory(n, false)
+		recordPhase("re-registration", time.Now())
+
+		By("Checking cluster state", func() {
+			WaitCluster(clusterNS, clusterName)
+			recordPhase("cluster rejoin", time.Now())
+			AddReportEntry("Reset total duration", time.Since(startTime).Round(time.Second).String())
 		})
 
-		By("Checking that MachineInventory is deleted", func() {
-			Eventually(func() string {
-				out, _ := kubectl.RunWithoutErr("get", "MachineInventory",
-					"--namespace", clusterNS,
-					"-o", "jsonpath={.items[*].metadata.name}")
-				return out
-			}, tools.SetTimeout(10*time.Minute), 5*time.Second).ShouldNot(ContainSubstring(firstMachineInventory))
+		checkResetNode(n)
+	})
+})
+
+var _ = Describe("E2E - Test the reset feature from recovery", Label("reset-recovery"), func() {
+	It("Reset one node from recovery mode", Label("qase-none"), func() {
+		// Get the machine inventory list
+		inventories, err := elemental.ListMachineInventories(clusterNS, "")
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(len(inventories)).To(BeNumerically(">", 1))
+
+		n := prepareReset(inventories[1])
+
+		By("Booting "+n.name+" in recovery mode", func() {
+			// Node is already in recovery, the reset plan of the operator cannot be applied
+			BootNodeInto(n.client, bootmode.Recovery)
 		})
 
-		By("Checking that MachineInventory is back after the reset", func() {
-			Eventually(func() string {
-				out, _ := kubectl.RunWithoutErr("get", "MachineInventory",
-					"--namespace", clusterNS,
-					"-o", "jsonpath={.items[*].metadata.name}")
-				return out
-			}, tools.SetTimeout(8*time.Minute), 5*time.Second).Should(ContainSubstring(firstMachineInventory))
+		triggerReset(n)
+
+		By("Resetting "+n.name+" from recovery mode", func() {
+			err := bootmode.ResetFromRecovery(n.client, tools.SetTimeout(20*time.Minute))
+			Expect(err).To(Not(HaveOccurred()))
 		})
 
+		waitResetInventory(n, false)
+
 		By("Checking cluster state", func() {
 			WaitCluster(clusterNS, clusterName)
 		})
+
+		// Persistent and OEM partitions should be wiped as with the operator reset
+		checkResetNode(n)
+	})
+})
+
+var _ = Describe("E2E - Test the reset feature on a pool", Label("reset-pool"), func() {
+	var wg sync.WaitGroup
+
+	It("Reset several nodes of a pool", Label("qase-none"), func() {
+		// Get the machine inventories of the pool
+		inventories, err := elemental.ListMachineInventories(clusterNS, "pool-type="+resetPool)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(inventories).To(Not(BeEmpty()))
+
+		// All MachineInventories should come back after the reset
+		allBefore, err := elemental.ListMachineInventories(clusterNS, "")
+		Expect(err).To(Not(HaveOccurred()))
+
+		// Reset only some nodes if asked
+		toReset := inventories
+		if resetNodes > 0 && resetNodes < len(inventories) {
+			toReset = inventories[:resetNodes]
+		}
+
+		var nodes []*resetNode
+		for _, mi := range toReset {
+			nodes = append(nodes, prepareReset(mi))
+		}
+
+		if resetPool == "master" {
+			// Masters are reset one at a time to keep the etcd quorum
+			Expect(len(inventories)).To(BeNumerically(">=", 3), "At least 3 master nodes are needed to keep quorum")
+
+			for _, n := range nodes {
+				var others []*tools.Client
+				for _, mi := range inventories {
+					if mi.Name != n.name {
+						others = append(others, clientFromInventory(mi))
+					}
+				}
+
+				stopWatch := watchQuorum(others)
+
+				triggerReset(n)
+				waitResetInventory(n, true)
+				waitResetInventory(n, false)
+
+				By("Checking cluster state after the reset of "+n.name, func() {
+					WaitCluster(clusterNS, clusterName)
+				})
+
+				By("Checking that quorum has been kept during the reset of "+n.name, func() {
+					Expect(stopWatch()).To(BeZero())
+				})
+
+				checkResetNode(n)
+			}
+		} else {
+			// All nodes are reset at the same time
+			for _, n := range nodes {
+				triggerReset(n)
+			}
+
+			for _, n := range nodes {
+				wg.Add(1)
+				go func(n *resetNode) {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					waitResetInventory(n, true)
+					waitResetInventory(n, false)
+				}(n)
+			}
+
+			// Wait for all parallel jobs
+			wg.Wait()
+
+			By("Checking cluster state", func() {
+				WaitCluster(clusterNS, clusterName)
+			})
+
+			for _, n := range nodes {
+				wg.Add(1)
+				go func(n *resetNode) {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					checkResetNode(n)
+				}(n)
+			}
+
+			// Wait for all parallel jobs
+			wg.Wait()
+		}
+
+		By("Checking that all MachineInventories are back with unique nodes and labels", func() {
+			after, err := elemental.ListMachineInventories(clusterNS, "")
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(after).To(HaveLen(len(allBefore)), "MachineInventories are missing or duplicated")
+
+			// A re-registered node should not take the identity of another one
+			inventories := map[string]elemental.MachineInventory{}
+			ips := map[string]string{}
+			hostnames := map[string]string{}
+			machines := map[string]string{}
+			for _, mi := range after {
+				inventories[mi.Name] = mi
+
+				Expect(mi.IP).To(Not(BeEmpty()))
+				Expect(ips).To(Not(HaveKey(mi.IP)), "Duplicated registration IP for "+mi.Name)
+				ips[mi.IP] = mi.Name
+
+				hostname := strings.TrimSpace(RunSSHWithRetry(clientFromInventory(mi), "hostname"))
+				Expect(hostnames).To(Not(HaveKey(hostname)), "Duplicated hostname for "+mi.Name)
+				hostnames[hostname] = mi.Name
+
+				machine, err := elemental.GetInternalMachine(clusterNS, mi.Name)
+				Expect(err).To(Not(HaveOccurred()))
+				Expect(strings.Fields(machine)).To(HaveLen(1), "Not exactly one Machine for "+mi.Name)
+				Expect(machines).To(Not(HaveKey(machine)), "Duplicated Machine for "+mi.Name)
+				machines[machine] = mi.Name
+			}
+
+			for _, n := range nodes {
+				Expect(inventories).To(HaveKey(n.name))
+
+				// Labels from MachineRegistration should be set again
+				diff := misc.DiffMaps(n.labels, inventories[n.name].Labels)
+				GinkgoWriter.Printf("Labels diff for %s:\n%s\n", n.name, diff)
+				Expect(diff.Check(misc.DiffExpectation{MustStay: []string{"cluster-id", "pool-type"}})).To(Succeed())
+			}
+		})
 	})
 })
@@ -0,0 +1,303 @@
+/*
+Copyright © 2022 - 2025 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e_test
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
+	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/bootmode"
+	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+	"github.com/rancher/elemental/tests/e2e/helpers/upgrade"
+)
+
+const failingUpgradeName = "failing-upgrade"
+
+/*
+Build and push an upgrade image that never boots in active mode
+  - @param baseImage Image used as a base
+  - @param repo Repository where to push the image
+  - @param tag Tag of the image
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func buildFailingImage(baseImage, repo, tag string) {
+	image := repo + ":" + tag
+
+	out, err := exec.Command("docker", "build",
+		"--build-arg", "BASE_IMAGE="+baseImage,
+		"--build-arg", "IMAGE_REPO="+repo,
+		"--build-arg", "IMAGE_TAG="+tag,
+		"--tag", image,
+		failingUpgradeDir).CombinedOutput()
+	Expect(err).To(Not(HaveOccurred()), string(out))
+
+	Eventually(func() error {
+		out, err := exec.Command("docker", "push", image).CombinedOutput()
+		GinkgoWriter.Printf("%s\n", out)
+		return err
+	}, tools.SetTimeout(5*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
+}
+
+/*
+Get the SUC plan created for an upgrade, from the downstream cluster
+  - @param cl Client (node) informations
+  - @param upgradeName Name of the ManagedOSImage
+  - @returns Name and latest hash of the plan, empty if not created yet
+*/
+func getUpgradePlan(cl *tools.Client, upgradeName string) (string, string) {
+	out, _ := cl.RunSSH("kubectl get plans.upgrade.cattle.io --namespace cattle-system" +
+		" -o jsonpath='{range .items[*]}{.metadata.name} {.status.latestHash}{\"\\n\"}{end}'")
+	for _, line := range strings.Split(out, "\n") {
+		name, hash, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if strings.Contains(name, upgradeName) && hash != "" {
+			return name, hash
+		}
+	}
+
+	return "", ""
+}
+
+/*
+Get the hash of the SUC plan last applied on a node
+  - @param cl Client (node) informations
+  - @param planName Name of the SUC plan
+  - @returns Hash of the plan, empty if never applied
+*/
+func getAppliedPlanHash(cl *tools.Client, planName string) string {
+	// SUC labels the node with the plan hash only when the upgrade job succeeds
+	label := strings.ReplaceAll("plan.upgrade.cattle.io/"+planName, ".", "\\.")
+	out, _ := cl.RunSSH("kubectl get node $(hostname) -o jsonpath='{.metadata.labels." + label + "}'")
+
+	return strings.TrimSpace(out)
+}
+
+/*
+Get the state of the SUC jobs of a plan for a node
+  - @param cl Client (node) informations
+  - @param planName Name of the SUC plan
+  - @param nodeName Kubernetes name of the node
+  - @returns Number of succeeded and failed job pods
+*/
+func getUpgradeJobs(cl *tools.Client, planName, nodeName string) (int, int) {
+	out, _ := cl.RunSSH("kubectl get jobs --namespace cattle-system" +
+		" --selector upgrade.cattle.io/plan=" + planName + ",upgrade.cattle.io/node=" + nodeName +
+		" -o jsonpath='{range .items[*]}{.status.succeeded}/{.status.failed}{\"\\n\"}{end}'")
+
+	var succeeded, failed int
+	for _, line := range strings.Split(out, "\n") {
+		// Fields are empty if there is no pod in this state
+		s, f, _ := strings.Cut(strings.TrimSpace(line), "/")
+		n, _ := strconv.Atoi(s)
+		succeeded += n
+		n, _ = strconv.Atoi(f)
+		failed += n
+	}
+
+	return succeeded, failed
+}
+
+var _ = Describe("E2E - Upgrading node with a broken image", Label("upgrade-rollback"), func() {
+	It("Fallback to passive snapshot after a failed upgrade", Label("qase-none"), func() {
+		// Only one node is used, to keep the cluster alive
+		hostName := elemental.SetHostname(vmNameRoot, vmIndex)
+		Expect(hostName).To(Not(BeEmpty()))
+
+		// Get node information
+		client, _ := GetNodeInfo(hostName)
+		Expect(client).To(Not(BeNil()))
+
+		// The image is pushed at each run, a public registry should not be used
+		Expect(failingImageRepo).To(Not(BeEmpty()), "FAILING_IMAGE_REPO should be set")
+
+		// Get current OS release and build the failing one from it
+		releaseBefore := getOSRelease(client)
+		Expect(releaseBefore["IMAGE"]).To(Not(BeEmpty()))
+		failingImageTag := releaseBefore.Tag()
+		if failingImageTag == "" {
+			failingImageTag = "latest"
+		}
+		failingImage := failingImageRepo + ":" + failingImageTag
+
+		// Kubernetes node name, set when the upgrade is triggered
+		var nodeName string
+
+		// Used to check what the MachineInventory reports after the fallback
+		annotationsBefore := getAnnotations(client)
+
+		By("Checking that suc-upgrade will apply the broken image", func() {
+			decision := upgrade.Decide(releaseBefore, upgrade.NewOSReleaseFromImage(failingImage), true, false)
+			Expect(decision).To(Equal(upgrade.Upgrade))
+		})
+
+		By("Building broken upgrade image "+failingImage, func() {
+			buildFailingImage(releaseBefore["IMAGE"], failingImageRepo, failingImageTag)
+		})
+
+		By("Enabling boot assessment on "+hostName, func() {
+			_ = RunSSHWithRetry(client, "grub2-editenv /oem/grubenv set enable_boot_assessment=yes")
+		})
+
+		By("Triggering upgrade with the broken image", func() {
+			// Set temporary file
+			upgradeTmp, err := tools.CreateTemp("upgrade")
+			Expect(err).To(Not(HaveOccurred()))
+			defer os.Remove(upgradeTmp)
+
+			// Get *REAL* hostname
+			nodeName = strings.Trim(RunSSHWithRetry(client, "hostname"), "\n")
+			selector, err := elemental.AddSelector("kubernetes.io/hostname", nodeName)
+			Expect(err).To(Not(HaveOccurred()), selector)
+
+			// Create new file for this specific upgrade
+			err = tools.AddDataToFile(upgradeSkelYaml, upgradeTmp, selector)
+			Expect(err).To(Not(HaveOccurred()))
+
+			// Patterns to replace
+			patterns := []YamlPattern{
+				{
+					key:   "with-%UPGRADE_TYPE%",
+					value: failingUpgradeName,
+				},
+				{
+					key:   "%UPGRADE_TYPE%",
+					value: "osImage: " + failingImage,
+				},
+				{
+					key:   "%CLUSTER_NAME%",
+					value: clusterName,
+				},
+				{
+					key:   "%FORCE_DOWNGRADE%",
+					value: strconv.FormatBool(true),
+				},
+				{
+					key:   "%UPGRADE_RECOVERY_ONLY%",
+					value: strconv.FormatBool(false),
+				},
+				{
+					key:   "%UPGRADE_RECOVERY%",
+					value: strconv.FormatBool(false),
+				},
+			}
+
+			// Create Yaml file
+			for _, p := range patterns {
+				err := tools.Sed(p.key, p.value, upgradeTmp)
+				Expect(err).To(Not(HaveOccurred()))
+			}
+
+			// Apply the generated file
+			err = kubectl.Apply(clusterNS, upgradeTmp)
+			Expect(err).To(Not(HaveOccurred()))
+		})
+
+		var planName, planHash string
+		By("Waiting for the upgrade plan on "+hostName, func() {
+			Eventually(func() string {
+				planName, planHash = getUpgradePlan(client, failingUpgradeName)
+				return planHash
+			}, tools.SetTimeout(5*time.Minute), 10*time.Second).Should(Not(BeEmpty()))
+		})
+
+		// State of the SUC jobs, as they are removed with the upgrade
+		var jobsSucceeded, jobsFailed int
+		By("Checking that "+hostName+" falls back to passive snapshot", func() {
+			// Marker files and kernel cmdline should both report passive mode
+			Eventually(func() (bootmode.Mode, error) {
+				return bootmode.Current(client)
+			}, tools.SetTimeout(20*time.Minute), 5*time.Second).Should(Equal(bootmode.Passive))
+
+			// Remove the upgrade at once, as the passive snapshot is seen as not upgraded
+			// and SUC would start the upgrade and reboot the node again
+			defer func() {
+				_, err := kubectl.RunWithoutErr("delete", "ManagedOSImage",
+					"--namespace", clusterNS, failingUpgradeName)
+				Expect(err).To(Not(HaveOccurred()))
+			}()
+
+			// Job pod is killed by the reboot into the broken system
+			Eventually(func() int {
+				jobsSucceeded, jobsFailed = getUpgradeJobs(client, planName, nodeName)
+				return jobsFailed
+			}, tools.SetTimeout(2*time.Minute), 5*time.Second).Should(BeNumerically(">", 0))
+		})
+
+		By("Checking that the upgrade is reported as failed on "+hostName, func() {
+			// No job pod succeeded, and the node is not labelled as upgraded by SUC
+			GinkgoWriter.Printf("SUC jobs of %s on %s: %d succeeded, %d failed\n", planName, nodeName, jobsSucceeded, jobsFailed)
+			Expect(jobsSucceeded).To(BeZero())
+			Expect(jobsFailed).To(BeNumerically(">", 0))
+			Expect(getAppliedPlanHash(client, planName)).To(Not(Equal(planHash)))
+		})
+
+		By("Checking that passive snapshot is the previous system on "+hostName, func() {
+			releaseAfter := getOSRelease(client)
+			Expect(releaseAfter["IMAGE"]).To(Equal(releaseBefore["IMAGE"]))
+		})
+
+		By("Checking grubenv after fallback on "+hostName, func() {
+			out := RunSSHWithRetry(client, "grub2-editenv /oem/grubenv list")
+			GinkgoWriter.Printf("grubenv on %s:\n%s\n", hostName, out)
+
+			// Boot assessment should still be enabled and no entry should be forced
+			Expect(out).To(ContainSubstring("enable_boot_assessment=yes"))
+			Expect(out).To(Not(ContainSubstring("next_entry=active")))
+		})
+
+		By("Checking that MachineInventory reports the previous system", func() {
+			// MachineInventory is updated by elemental-register at each boot, so the annotations
+			// reporting the OS image should still be the ones of the system before the upgrade
+			keys := getOSAnnotations(annotationsBefore, releaseBefore, upgrade.NewOSReleaseFromImage(failingImage))
+			Expect(keys).To(Not(BeEmpty()), "No annotation reports the OS image or version!")
+
+			annotations := getAnnotations(client)
+			diff := misc.DiffMaps(annotationsBefore, annotations)
+			GinkgoWriter.Printf("Annotations diff for %s:\n%s\n", hostName, diff)
+			Expect(diff.Check(misc.DiffExpectation{MustStay: keys})).To(Succeed())
+
+			// Broken image should never appear
+			for k, v := range annotations {
+				Expect(v).To(Not(ContainSubstring(failingImageRepo)), k)
+			}
+		})
+
+		By("Disabling boot assessment on "+hostName, func() {
+			_ = RunSSHWithRetry(client, "grub2-editenv /oem/grubenv unset enable_boot_assessment")
+		})
+
+		// Active snapshot is still the broken one, it would reboot in a loop
+		By("Restoring the previous system as active snapshot on "+hostName, func() {
+			BootNodeInto(client, bootmode.Recovery)
+
+			err := bootmode.UpgradeFromRecovery(client, releaseBefore["IMAGE"], tools.SetTimeout(20*time.Minute))
+			Expect(err).To(Not(HaveOccurred()))
+
+			releaseAfter := getOSRelease(client)
+			Expect(releaseAfter["IMAGE"]).To(Equal(releaseBefore["IMAGE"]))
+		})
+
+		By("Checking cluster state after fallback", func() {
+			WaitCluster(clusterNS, clusterName)
+		})
+	})
+})
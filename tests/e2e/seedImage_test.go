@@ -16,7 +16,6 @@ package e2e_test
 
 import (
 	"os"
-	"os/exec"
 	"strconv"
 	"time"
 
@@ -38,15 +37,12 @@ var _ = Describe("E2E - Creating ISO image", Label("iso-image"), func() {
 		seedImageName = "seed-image-" + poolType + "-" + clusterName
 	})
 
-	It("Configure and create ISO image", func() {
-		// Report to Qase
-		testCaseID = 38
-
+	It("Configure and create ISO image", Label("qase-38"), func() {
 		By("Adding SeedImage", func() {
 			var (
 				baseImageURL string
 				err          error
-				OSVersion    []byte
+				OSVersion    string
 			)
 
 			if selinux {
@@ -58,12 +54,12 @@ var _ = Describe("E2E - Creating ISO image", Label("iso-image"), func() {
 
 				// Get OSVersion name
 				Eventually(func() string {
-					OSVersion, _ = exec.Command(getOSScript, os2Test, "true").Output()
-					return string(OSVersion)
+					OSVersion = GetOSVersionName(os2Test, elemental.OSVersionISO)
+					return OSVersion
 				}, tools.SetTimeout(2*time.Minute), 30*time.Second).Should(Not(BeEmpty()))
 
 				// Extract container image URL
-				baseImageURL, err = elemental.GetImageURI(clusterNS, string(OSVersion))
+				baseImageURL, err = elemental.GetImageURI(clusterNS, OSVersion)
 				Expect(err).To(Not(HaveOccurred()))
 			}
 
@@ -142,10 +138,7 @@ var _ = Describe("E2E - Creating ISO image", Label("iso-image"), func() {
 		})
 	})
 
-	It("Download ISO built by SeedImage", func() {
-		// Report to Qase
-		testCaseID = 39
-
+	It("Download ISO built by SeedImage", Label("qase-39"), func() {
 		DownloadBuiltISO(clusterNS, seedImageName, "../../elemental-"+poolType+".iso")
 	})
 })
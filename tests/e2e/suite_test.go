@@ -15,32 +15,49 @@ limitations under the License.
 package e2e_test
 
 import (
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
+	"github.com/onsi/ginkgo/v2/types"
 	. "github.com/onsi/gomega"
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/rancher"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
 	. "github.com/rancher-sandbox/qase-ginkgo"
+	"github.com/rancher/elemental/tests/e2e/helpers/bootmode"
+	"github.com/rancher/elemental/tests/e2e/helpers/diagnostics"
 	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/events"
+	"github.com/rancher/elemental/tests/e2e/helpers/fingerprint"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+	"github.com/rancher/elemental/tests/e2e/helpers/qaseapi"
+	"github.com/rancher/elemental/tests/e2e/helpers/s3"
+	"github.com/rancher/elemental/tests/e2e/helpers/snapshot"
+	"github.com/rancher/elemental/tests/e2e/helpers/specs"
+	"github.com/rancher/elemental/tests/e2e/helpers/timing"
+	"github.com/rancher/elemental/tests/e2e/helpers/upstream"
 )
 
 const (
 	airgapBuildScript     = "../scripts/build-airgap"
 	appYaml               = "../assets/hello-world_app.yaml"
+	backupEncryptedYaml   = "../assets/backup-encrypted.yaml"
 	backupYaml            = "../assets/backup.yaml"
 	ciTokenYaml           = "../assets/local-kubeconfig-token-skel.yaml"
 	configPrivateCAScript = "../scripts/config-private-ca"
-	configRKE2Yaml        = "../assets/config_rke2.yaml"
+	configUpstreamDir     = "../assets"
+	diagnosticsLines      = 200
 	dumbRegistrationYaml  = "../assets/dumb_machineRegistration.yaml"
 	emulateTPMYaml        = "../assets/emulateTPM.yaml"
-	getOSScript           = "../scripts/get-name-from-managedosversion"
+	failingUpgradeDir     = "../assets/failing_upgrade"
 	httpSrv               = "http://192.168.122.1:8000"
 	installConfigYaml     = "../../install-config.yaml"
 	installHardenedScript = "../scripts/config-hardened"
@@ -49,8 +66,15 @@ const (
 	localStorageYaml      = "../assets/local-storage.yaml"
 	metallbRscYaml        = "../assets/metallb_rsc.yaml"
 	numberOfNodesMax      = 30
+	proxyPolicyYaml       = "../assets/proxy-policy.yaml"
 	resetMachineInv       = "../assets/reset_machine_inventory.yaml"
+	restoreEncryptedYaml  = "../assets/restore-encrypted.yaml"
 	restoreYaml           = "../assets/restore.yaml"
+	s3Bucket              = "elemental-backup"
+	s3ContainerName       = "elemental-e2e-s3"
+	s3CredentialSecret    = "elemental-e2e-s3-creds"
+	s3Image               = "quay.io/minio/minio:RELEASE.2024-10-13T13-34-11Z"
+	s3Region              = "us-east-1"
 	sshConfigFile         = "../assets/ssh_config"
 	upgradeSkelYaml       = "../assets/upgrade_skel.yaml"
 	userName              = "root"
@@ -60,14 +84,19 @@ const (
 
 var (
 	backupRestoreVersion      string
+	backupStorage             string
 	caType                    string
 	certManagerVersion        string
+	clusterLayout             string
 	clusterName               string
 	clusterNS                 string
 	clusterType               string
 	clusterYaml               string
+	diagnosticsFile           string
 	elementalSupport          string
 	emulateTPM                bool
+	eventRecorder             *events.Recorder
+	failingImageRepo          string
 	forceDowngrade            bool
 	isoBoot                   bool
 	k8sUpstreamVersion        string
@@ -81,6 +110,7 @@ var (
 	os2Test                   string
 	poolType                  string
 	proxy                     string
+	qaseSpoolDir              string
 	rancherChannel            string
 	rancherHeadVersion        string
 	rancherHostname           string
@@ -91,7 +121,12 @@ var (
 	rancherUpgradeHeadVersion string
 	rancherUpgradeVersion     string
 	rawBoot                   bool
+	s3AccessKey               string
+	s3Endpoint                string
+	s3SecretKey               string
 	registrationYaml          string
+	resetNodes                int
+	resetPool                 string
 	seedImageYaml             string
 	selectorYaml              string
 	selinux                   bool
@@ -100,14 +135,20 @@ var (
 	sshdConfigFile            string
 	testCaseID                int64
 	testType                  string
+	upstreamCluster           upstream.UpstreamCluster
 	upgradeImage              string
 	upgradeOSChannel          string
+	upgradeRecovery           bool
+	upgradeRecoveryOnly       bool
 	upgradeType               string
 	usedNodes                 int
 	vmIndex                   int
 	vmName                    string
 )
 
+// Absolute path, as the current directory is changed when logs are collected
+var stepsReportDir, _ = filepath.Abs("logs")
+
 func CheckBackupRestore(v string) {
 	Eventually(func() string {
 		out, _ := kubectl.RunWithoutErr("logs", "-l app.kubernetes.io/name=rancher-backup",
@@ -117,6 +158,45 @@ func CheckBackupRestore(v string) {
 	}, tools.SetTimeout(5*time.Minute), 10*time.Second).Should(ContainSubstring(v))
 }
 
+/*
+Capture a fingerprint of Elemental resources and save it for debugging purposes
+  - @param name Name of the fingerprint, used in the file name
+  - @returns The fingerprint
+*/
+func CaptureFingerprint(name string) fingerprint.Fingerprint {
+	var f fingerprint.Fingerprint
+
+	Eventually(func() error {
+		var err error
+		f, err = fingerprint.Capture()
+		return err
+	}, tools.SetTimeout(2*time.Minute), 10*time.Second).Should(Not(HaveOccurred()))
+
+	// Keep the file with the other reports
+	err := os.MkdirAll(stepsReportDir, 0755)
+	Expect(err).To(Not(HaveOccurred()))
+	err = f.Save(filepath.Join(stepsReportDir, "fingerprint-"+name+".json"))
+	Expect(err).To(Not(HaveOccurred()))
+
+	return f
+}
+
+/*
+Check that Elemental resources have not been changed
+  - @param before Fingerprint captured before the operation
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func CheckFingerprint(before fingerprint.Fingerprint) {
+	// Resources could be re-created/updated asynchronously after a restore
+	Eventually(func() string {
+		after, err := fingerprint.Capture()
+		if err != nil {
+			return err.Error()
+		}
+		return fingerprint.Compare(before, after, nil).String()
+	}, tools.SetTimeout(5*time.Minute), 20*time.Second).Should(BeEmpty())
+}
+
 /*
 Check that Cluster resource has been correctly created
   - @param ns Namespace where the cluster is deployed
@@ -163,6 +243,58 @@ func CheckCreatedSelectorTemplate(ns, sn string) {
 	}, tools.SetTimeout(3*time.Minute), 5*time.Second).Should(ContainSubstring(sn))
 }
 
+/*
+Reboot a node in a specific boot mode
+  - @param cl Client (node) informations
+  - @param mode Boot mode to use (active, passive or recovery)
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func BootNodeInto(cl *tools.Client, mode bootmode.Mode) {
+	err := bootmode.BootInto(cl, mode, tools.SetTimeout(10*time.Minute))
+	Expect(err).To(Not(HaveOccurred()))
+}
+
+/*
+Reboot a node in the default (active) boot mode
+  - @param cl Client (node) informations
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func RestoreBootMode(cl *tools.Client) {
+	err := bootmode.Restore(cl, tools.SetTimeout(10*time.Minute))
+	Expect(err).To(Not(HaveOccurred()))
+}
+
+/*
+Check that the configured snapshotter is used on a node
+  - @param cl Client (node) informations
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func CheckSnapshotter(cl *tools.Client) {
+	used, err := snapshot.Detect(cl)
+	Expect(err).To(Not(HaveOccurred()))
+	Expect(used).To(Equal(snapshot.Type(snapType)))
+}
+
+/*
+Check snapshots after an upgrade
+  - @param before Snapshots before the upgrade
+  - @param after Snapshots after the upgrade
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func CheckUpgradeSnapshots(before, after []snapshot.Snapshot) {
+	// Exactly one snapshot should be created and used
+	added := snapshot.Added(before, after)
+	Expect(added).To(HaveLen(1))
+	Expect(added[0].Active).To(BeTrue())
+
+	active, err := snapshot.Active(after)
+	Expect(err).To(Not(HaveOccurred()))
+	Expect(active).To(Equal(added[0]))
+
+	// Old snapshots should be cleaned up
+	Expect(after).To(HaveLen(min(len(before)+1, snapshot.MaxSnapshots(snapType))))
+}
+
 /*
 Check SSH connection
   - @param cl Client (node) informations
@@ -237,6 +369,26 @@ func DownloadBuiltISO(ns, seedName, filename string) {
 	}
 }
 
+/*
+Get the snapshots of a node
+  - @param cl Client (node) informations
+  - @returns List of snapshots sorted by ID
+*/
+func GetSnapshots(cl *tools.Client) []snapshot.Snapshot {
+	var snapshots []snapshot.Snapshot
+
+	Eventually(func() error {
+		var err error
+		snapshots, err = snapshot.List(cl, snapType)
+		return err
+	}, tools.SetTimeout(2*time.Minute), 20*time.Second).Should(Not(HaveOccurred()))
+
+	// For debugging purposes
+	GinkgoWriter.Printf("Snapshots on %s: %+v\n", cl.Host, snapshots)
+
+	return snapshots
+}
+
 /*
 Get configured backup directory
   - @returns Configured backup directory
@@ -255,6 +407,24 @@ func GetBackupDir() string {
 	return out
 }
 
+/*
+Get the name of the newest ManagedOSVersion
+  - @param kind Kind of OS to test (e.g. dev, staging or stable)
+  - @param t Type of ManagedOSVersion (container or iso)
+  - @returns Name of the ManagedOSVersion, empty if nothing is found
+*/
+func GetOSVersionName(kind, t string) string {
+	version, err := elemental.GetLatestManagedOSVersion(clusterNS, elemental.OSVersionQuery{
+		Type:   t,
+		Stable: elemental.IsStableOSKind(kind),
+	})
+	if err != nil {
+		GinkgoWriter.Printf("ManagedOSVersion not found: %s\n", err)
+	}
+
+	return version.Name
+}
+
 /*
 Get Elemental node information
   - @param hn Node hostname
@@ -322,10 +492,39 @@ func InstallBackupOperator(k *kubectl.Kubectl) {
 
 		// Add specific options for the rancher-backup chart
 		if chart == "rancher-backup" {
-			flags = append(flags,
-				"--set", "persistence.enabled=true",
-				"--set", "persistence.storageClass=local-path",
-			)
+			if backupStorage == "s3" {
+				StartS3Server()
+
+				// Credentials are read by the operator during backup/restore
+				out, err := kubectl.RunWithoutErr("create", "secret", "generic", s3CredentialSecret,
+					"--namespace", "cattle-resources-system",
+					"--from-literal=accessKey="+s3AccessKey,
+					"--from-literal=secretKey="+s3SecretKey,
+					"--dry-run=client", "-o", "yaml")
+				Expect(err).To(Not(HaveOccurred()))
+
+				secretFile, err := tools.CreateTemp("s3-secret")
+				Expect(err).To(Not(HaveOccurred()))
+				defer os.Remove(secretFile)
+				Expect(tools.WriteFile(secretFile, []byte(out))).To(Succeed())
+				Expect(kubectl.Apply("cattle-resources-system", secretFile)).To(Succeed())
+
+				flags = append(flags,
+					"--set", "persistence.enabled=false",
+					"--set", "s3.enabled=true",
+					"--set", "s3.bucketName="+s3Bucket,
+					"--set", "s3.credentialSecretName="+s3CredentialSecret,
+					"--set", "s3.credentialSecretNamespace=cattle-resources-system",
+					"--set", "s3.endpoint="+s3Endpoint,
+					"--set", "s3.insecureTLSSkipVerify=true",
+					"--set", "s3.region="+s3Region,
+				)
+			} else {
+				flags = append(flags,
+					"--set", "persistence.enabled=true",
+					"--set", "persistence.storageClass=local-path",
+				)
+			}
 		}
 
 		RunHelmCmdWithRetry(flags...)
@@ -336,6 +535,96 @@ func InstallBackupOperator(k *kubectl.Kubectl) {
 	}
 }
 
+/*
+Start a S3-compatible server on the host, if not already running
+  - @returns Nothing, the function will fail through Ginkgo in case of issue
+*/
+func StartS3Server() {
+	// Nothing to do if the server is already running, e.g. after a K8s re-installation
+	out, err := exec.Command("docker", "ps", "--quiet", "--filter", "name=^"+s3ContainerName+"$").Output()
+	Expect(err).To(Not(HaveOccurred()))
+
+	if strings.TrimSpace(string(out)) == "" {
+		host, port, err := net.SplitHostPort(s3Endpoint)
+		Expect(err).To(Not(HaveOccurred()))
+
+		// TLS is needed by the backup operator
+		certDir, err := os.MkdirTemp("", "s3-certs")
+		Expect(err).To(Not(HaveOccurred()))
+		cert, key, err := s3.GenerateCertificate(host, 24*time.Hour)
+		Expect(err).To(Not(HaveOccurred()))
+		Expect(os.WriteFile(certDir+"/public.crt", cert, 0644)).To(Succeed())
+		Expect(os.WriteFile(certDir+"/private.key", key, 0644)).To(Succeed())
+
+		out, err := exec.Command("docker", "run", "--detach", "--rm",
+			"--name", s3ContainerName,
+			"--publish", port+":9000",
+			"--env", "MINIO_ROOT_USER="+s3AccessKey,
+			"--env", "MINIO_ROOT_PASSWORD="+s3SecretKey,
+			"--volume", certDir+":/certs:ro",
+			s3Image, "server", "/data", "--certs-dir", "/certs").CombinedOutput()
+		Expect(err).To(Not(HaveOccurred()), string(out))
+	}
+
+	// Wait for the server and create the bucket
+	Eventually(func() error {
+		return GetS3Client().CreateBucket(s3Bucket)
+	}, tools.SetTimeout(2*time.Minute), 5*time.Second).Should(Not(HaveOccurred()))
+}
+
+/*
+Get a client for the S3-compatible server
+  - @returns The S3 client
+*/
+func GetS3Client() *s3.Client {
+	return &s3.Client{
+		Endpoint:  "https://" + s3Endpoint,
+		Region:    s3Region,
+		AccessKey: s3AccessKey,
+		SecretKey: s3SecretKey,
+		Insecure:  true,
+	}
+}
+
+/*
+Capture diagnostics for the current (failed) spec
+  - @returns Name of the diagnostics file, also attached to the report
+*/
+func CaptureDiagnostics() string {
+	nodes := map[string]*tools.Client{}
+
+	// Nodes may not be registered yet, diagnostics are captured anyway
+	inventories, err := elemental.ListMachineInventories(clusterNS, "")
+	if err != nil {
+		GinkgoWriter.Printf("Cannot list nodes for diagnostics: %s\n", err)
+	}
+	for _, mi := range inventories {
+		if mi.IP != "" {
+			nodes[mi.Name] = &tools.Client{
+				Host:     mi.IP + ":22",
+				Username: userName,
+				Password: userPassword,
+			}
+		}
+	}
+
+	snapshot := diagnostics.Capture(nodes, diagnosticsLines)
+	AddReportEntry("Diagnostics", snapshot.String(), ReportEntryVisibilityFailureOrVerbose)
+
+	// Keep the file with the other reports, not in the current directory
+	if err := os.MkdirAll(stepsReportDir, 0755); err != nil {
+		GinkgoWriter.Printf("Cannot write diagnostics: %s\n", err)
+		return ""
+	}
+	file := filepath.Join(stepsReportDir, diagnostics.FileName("diagnostics", CurrentSpecReport().FullText()))
+	if err := snapshot.WriteFile(file); err != nil {
+		GinkgoWriter.Printf("Cannot write diagnostics: %s\n", err)
+		return ""
+	}
+
+	return file
+}
+
 /*
 Install CertManager
   - @param k kubectl structure
@@ -419,32 +708,6 @@ func InstallLocalStorage(k *kubectl.Kubectl) {
 	}, tools.SetTimeout(2*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
 }
 
-/*
-Install K3s
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func InstallK3s() {
-	// Get K3s installation script
-	fileName := "k3s-install.sh"
-	Eventually(func() error {
-		return tools.GetFileFromURL("https://get.k3s.io", fileName, true)
-	}, tools.SetTimeout(2*time.Minute), 10*time.Second).ShouldNot(HaveOccurred())
-
-	// Set command and arguments
-	installCmd := exec.Command("sh", fileName)
-	installCmd.Env = append(os.Environ(), "INSTALL_K3S_EXEC=--disable metrics-server")
-
-	// Retry in case of (sporadic) failure...
-	count := 1
-	Eventually(func() error {
-		// Execute K3s installation
-		out, err := installCmd.CombinedOutput()
-		GinkgoWriter.Printf("K3s installation loop %d:\n%s\n", count, out)
-		count++
-		return err
-	}, tools.SetTimeout(2*time.Minute), 5*time.Second).Should(Not(HaveOccurred()))
-}
-
 /*
 Install Rancher Manager
   - @param k kubectl structure
@@ -466,28 +729,6 @@ func InstallRancher(k *kubectl.Kubectl) {
 	}, tools.SetTimeout(10*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
 }
 
-/*
-Install RKE2
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func InstallRKE2() {
-	// Get RKE2 installation script
-	fileName := "rke2-install.sh"
-	Eventually(func() error {
-		return tools.GetFileFromURL("https://get.rke2.io", fileName, true)
-	}, tools.SetTimeout(2*time.Minute), 10*time.Second).ShouldNot(HaveOccurred())
-
-	// Retry in case of (sporadic) failure...
-	count := 1
-	Eventually(func() error {
-		// Execute RKE2 installation
-		out, err := exec.Command("sudo", "--preserve-env=INSTALL_RKE2_VERSION", "sh", fileName).CombinedOutput()
-		GinkgoWriter.Printf("RKE2 installation loop %d:\n%s\n", count, out)
-		count++
-		return err
-	}, tools.SetTimeout(2*time.Minute), 5*time.Second).Should(Not(HaveOccurred()))
-}
-
 /*
 Execute RunHelmBinaryWithCustomErr within a loop with timeout
   - @param s options to pass to RunHelmBinaryWithCustomErr command
@@ -517,40 +758,6 @@ func RunSSHWithRetry(cl *tools.Client, cmd string) string {
 	return out
 }
 
-/*
-Start K3s
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func StartK3s() {
-	err := exec.Command("sudo", "systemctl", "start", "k3s").Run()
-	Expect(err).To(Not(HaveOccurred()))
-}
-
-/*
-Start RKE2
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func StartRKE2() {
-	// Copy config file, this allows custom configuration for RKE2 installation
-	// NOTE: CopyFile cannot be used, as we need root permissions for this file
-	err := exec.Command("sudo", "mkdir", "-p", "/etc/rancher/rke2").Run()
-	Expect(err).To(Not(HaveOccurred()))
-	err = exec.Command("sudo", "cp", configRKE2Yaml, "/etc/rancher/rke2/config.yaml").Run()
-	Expect(err).To(Not(HaveOccurred()))
-
-	// Activate and start RKE2
-	err = exec.Command("sudo", "systemctl", "enable", "--now", "rke2-server.service").Run()
-	Expect(err).To(Not(HaveOccurred()))
-
-	// Be sure that any previous kubectl command has been removed before linking the new one
-	err = exec.Command("sudo", "rm", "-f", "/usr/local/bin/kubectl").Run()
-	Expect(err).To(Not(HaveOccurred()))
-
-	// Create kubectl link
-	err = exec.Command("sudo", "ln", "-s", "/var/lib/rancher/rke2/bin/kubectl", "/usr/local/bin/kubectl").Run()
-	Expect(err).To(Not(HaveOccurred()))
-}
-
 /*
 Wait for cluster to be in a stable state
   - @param ns Namespace where the cluster is deployed
@@ -669,60 +876,6 @@ func WaitCluster(ns, cn string) {
 	}
 }
 
-/*
-Wait for K3s to start
-  - @param k kubectl structure
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func WaitForK3s(k *kubectl.Kubectl) {
-	// Check Pod(s)
-	checkList := [][]string{
-		{"kube-system", "app=local-path-provisioner"},
-		{"kube-system", "k8s-app=kube-dns"},
-		{"kube-system", "app.kubernetes.io/name=traefik"},
-		{"kube-system", "svccontroller.k3s.cattle.io/svcname=traefik"},
-	}
-	Eventually(func() error {
-		return rancher.CheckPod(k, checkList)
-	}, tools.SetTimeout(4*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
-
-	// Check DaemonSet(s)
-	checkList = [][]string{
-		{"kube-system", "svccontroller.k3s.cattle.io/svcname=traefik"},
-	}
-	Eventually(func() error {
-		return rancher.CheckDaemonSet(k, checkList)
-	}, tools.SetTimeout(4*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
-}
-
-/*
-Wait for RKE2 to start
-  - @param k kubectl structure
-  - @returns Nothing, the function will fail through Ginkgo in case of issue
-*/
-func WaitForRKE2(k *kubectl.Kubectl) {
-	err := os.Setenv("KUBECONFIG", "/etc/rancher/rke2/rke2.yaml")
-	Expect(err).To(Not(HaveOccurred()))
-
-	// Check Pod(s)
-	checkList := [][]string{
-		{"kube-system", "k8s-app=kube-dns"},
-		{"kube-system", "app.kubernetes.io/name=rke2-ingress-nginx"},
-	}
-	Eventually(func() error {
-		return rancher.CheckPod(k, checkList)
-	}, tools.SetTimeout(4*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
-
-	// Check DaemonSet(s)
-	checkList = [][]string{
-		{"kube-system", "k8s-app=canal"},
-		{"kube-system", "app.kubernetes.io/instance=rke2-ingress-nginx"},
-	}
-	Eventually(func() error {
-		return rancher.CheckDaemonSet(k, checkList)
-	}, tools.SetTimeout(4*time.Minute), 30*time.Second).Should(Not(HaveOccurred()))
-}
-
 /*
 Wait for OSVersion to be populated
   - @param ns Namespace where the cluster is deployed
@@ -737,6 +890,20 @@ func WaitForOSVersion(ns string) {
 	}, tools.SetTimeout(2*time.Minute), 5*time.Second).Should(Not(BeEmpty()))
 }
 
+/*
+Get the name of the reports of the suite run
+  - @returns The label filter as a slug, "all" if no filter
+*/
+func reportName() string {
+	// Each make target is a different suite run
+	name := misc.Slug(GinkgoLabelFilter())
+	if name == "" {
+		name = "all"
+	}
+
+	return name
+}
+
 func FailWithReport(message string, callerSkip ...int) {
 	// Ensures the correct line numbers are reported
 	Fail(message, callerSkip[0]+1)
@@ -763,28 +930,41 @@ var _ = BeforeSuite(func() {
 	clusterType = os.Getenv("CLUSTER_TYPE")
 	elementalSupport = os.Getenv("ELEMENTAL_SUPPORT")
 	eTPM := os.Getenv("EMULATE_TPM")
+	failingImageRepo = os.Getenv("FAILING_IMAGE_REPO")
 	forceDowngradeString := os.Getenv("FORCE_DOWNGRADE")
 	index := os.Getenv("VM_INDEX")
 	k8sDownstreamVersion = os.Getenv("K8S_DOWNSTREAM_VERSION")
+	k8sUpstreamArtifacts := os.Getenv("K8S_UPSTREAM_ARTIFACTS")
+	k8sUpstreamDropIns := os.Getenv("K8S_UPSTREAM_DROPINS")
 	k8sUpstreamVersion = os.Getenv("K8S_UPSTREAM_VERSION")
 	number := os.Getenv("VM_NUMBERS")
 	clusterNumber := os.Getenv("CLUSTER_NUMBER")
+	clusterLayout = os.Getenv("CLUSTER_LAYOUT")
 	operatorInstallType = os.Getenv("OPERATOR_INSTALL_TYPE")
 	operatorRepo = os.Getenv("OPERATOR_REPO")
 	operatorUpgrade = os.Getenv("OPERATOR_UPGRADE")
 	os2Test = os.Getenv("OS_TO_TEST")
 	poolType = os.Getenv("POOL")
 	proxy = os.Getenv("PROXY")
+	qaseSpoolDir = os.Getenv("QASE_SPOOL_DIR")
 	rancherHostname = os.Getenv("PUBLIC_FQDN")
 	rancherLogCollector = os.Getenv("RANCHER_LOG_COLLECTOR")
 	rancherVersion = os.Getenv("RANCHER_VERSION")
 	rancherUpgrade = os.Getenv("RANCHER_UPGRADE")
+	backupStorage = os.Getenv("BACKUP_STORAGE")
+	s3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	s3Endpoint = os.Getenv("S3_ENDPOINT")
+	s3SecretKey = os.Getenv("S3_SECRET_KEY")
+	resetNodesString := os.Getenv("RESET_NODES")
+	resetPool = os.Getenv("RESET_POOL")
 	selinuxString := os.Getenv("SELINUX")
 	seqString := os.Getenv("SEQUENTIAL")
 	snapType = os.Getenv("SNAP_TYPE")
 	testType = os.Getenv("TEST_TYPE")
 	upgradeImage = os.Getenv("UPGRADE_IMAGE")
 	upgradeOSChannel = os.Getenv("UPGRADE_OS_CHANNEL")
+	upgradeRecoveryString := os.Getenv("UPGRADE_RECOVERY")
+	upgradeRecoveryOnlyString := os.Getenv("UPGRADE_RECOVERY_ONLY")
 	upgradeType = os.Getenv("UPGRADE_TYPE")
 
 	// Define boot type
@@ -811,6 +991,44 @@ var _ = BeforeSuite(func() {
 		forceDowngrade = false
 	}
 
+	// Default S3 server is started on the host, reachable by pods and nodes
+	if s3Endpoint == "" {
+		s3Endpoint = "192.168.122.1:9000"
+	}
+	if s3AccessKey == "" {
+		s3AccessKey = "elemental-e2e"
+	}
+	if s3SecretKey == "" {
+		// Each make target is a different run, the key must be the same for all of them
+		s3SecretKey = "elemental-e2e-secret"
+	}
+
+	// Reset all nodes of the worker pool by default
+	if resetPool == "" {
+		resetPool = "worker"
+	}
+	if resetNodesString != "" {
+		var err error
+		resetNodes, err = strconv.Atoi(resetNodesString)
+		Expect(err).To(Not(HaveOccurred()))
+	}
+
+	// Force correct value for upgradeRecovery
+	switch upgradeRecoveryString {
+	case "true":
+		upgradeRecovery = true
+	default:
+		upgradeRecovery = false
+	}
+
+	// Force correct value for upgradeRecoveryOnly
+	switch upgradeRecoveryOnlyString {
+	case "true":
+		upgradeRecoveryOnly = true
+	default:
+		upgradeRecoveryOnly = false
+	}
+
 	// Only if VM_INDEX is set
 	if index != "" {
 		var err error
@@ -920,20 +1138,138 @@ var _ = BeforeSuite(func() {
 		selectorYaml = "../assets/selector.yaml"
 	}
 
+	// Upstream cluster, air-gapped if artifacts are available locally
+	upstreamCluster = upstream.New(k8sUpstreamVersion, upstream.Options{
+		Artifacts: k8sUpstreamArtifacts,
+		// This allows custom configuration for the installation, one file per distribution
+		Config:  filepath.Join(configUpstreamDir, "config_"+upstream.Distribution(k8sUpstreamVersion)+".yaml"),
+		DropIns: strings.Fields(strings.ReplaceAll(k8sUpstreamDropIns, ",", " ")),
+		Output:  GinkgoWriter,
+	})
+
+	// Absolute path, as the current directory is changed when logs are collected
+	if qaseSpoolDir != "" {
+		var err error
+		qaseSpoolDir, err = filepath.Abs(qaseSpoolDir)
+		Expect(err).To(Not(HaveOccurred()))
+	}
+
 	// Set number of "used" nodes
 	// NOTE: could be the number of added nodes or the number of nodes to use/upgrade
 	usedNodes = (numberOfVMs - vmIndex) + 1
 
+	// Record Events for the whole suite, the cluster may not be installed yet
+	err := os.MkdirAll(stepsReportDir, 0755)
+	Expect(err).To(Not(HaveOccurred()))
+	eventRecorder, err = events.NewRecorder(filepath.Join(stepsReportDir, "events-"+reportName()+".jsonl"),
+		"cattle-elemental-system", "cattle-fleet-local-system", "cattle-system", clusterNS)
+	Expect(err).To(Not(HaveOccurred()))
+	eventRecorder.Start()
+
 	// Final step: start local HTTP server
 	tools.HTTPShare("../..", ":8000")
 })
 
+var _ = AfterSuite(func() {
+	if eventRecorder != nil {
+		err := eventRecorder.Stop()
+		Expect(err).To(Not(HaveOccurred()))
+	}
+})
+
+var _ = AfterEach(func() {
+	// Capture the state now, it is lost when the logs are collected at the end
+	if CurrentSpecReport().Failed() {
+		diagnosticsFile = CaptureDiagnostics()
+	}
+})
+
 var _ = ReportBeforeEach(func(report SpecReport) {
-	// Reset case ID
-	testCaseID = -1
+	// Case ID is declared with a label, the spec sets it if it depends on the test environment
+	testCaseID = specs.CaseID(report.Labels())
+	diagnosticsFile = ""
 })
 
 var _ = ReportAfterEach(func(report SpecReport) {
+	// Annotate the Events received during the spec
+	if eventRecorder != nil {
+		if err := eventRecorder.Flush(report); err != nil {
+			GinkgoWriter.Printf("Events not recorded: %s\n", err)
+		}
+	}
+
+	// Qase API not reachable, results are uploaded later with "qase_cmd -upload-spool"
+	if qaseSpoolDir != "" {
+		var files []string
+		if report.Failed() && diagnosticsFile != "" {
+			files = append(files, diagnosticsFile)
+		}
+		if err := qaseapi.SpoolSpec(qaseSpoolDir, testCaseID, report, files...); err != nil {
+			GinkgoWriter.Printf("Result not spooled: %s\n", err)
+		}
+		return
+	}
+
+	// Send failed result with diagnostics if possible
+	if report.Failed() && diagnosticsFile != "" {
+		err := diagnostics.SendToQase(testCaseID, report, diagnosticsFile)
+		if err == nil {
+			return
+		}
+		GinkgoWriter.Printf("Diagnostics not sent to Qase: %s\n", err)
+	}
+
 	// Add result in Qase if asked
 	Qase(testCaseID, report)
 })
+
+var _ = ReportAfterSuite("Steps timing", func(report Report) {
+	// Nothing to report, e.g. no spec matched the label filter
+	if report.PreRunStats.SpecsThatWillRun == 0 {
+		return
+	}
+
+	e := timing.Extractor{
+		Node: regexp.MustCompile(regexp.QuoteMeta(vmNameRoot) + `-[a-z0-9-]*\d{3}`),
+	}
+	if clusterName != "" {
+		e.Cluster = regexp.MustCompile(regexp.QuoteMeta(clusterName) + `[a-z0-9-]*`)
+	}
+	steps := timing.Steps(report, e)
+	name := reportName()
+
+	err := os.MkdirAll(stepsReportDir, 0755)
+	Expect(err).To(Not(HaveOccurred()))
+
+	err = timing.WriteJSON(steps, filepath.Join(stepsReportDir, "steps-"+name+".json"))
+	Expect(err).To(Not(HaveOccurred()))
+
+	err = timing.WriteJUnit(steps, report.SuiteDescription+" - "+name, filepath.Join(stepsReportDir, "steps-"+name+".xml"))
+	Expect(err).To(Not(HaveOccurred()))
+})
+
+var _ = ReportAfterSuite("Events summary", func(report Report) {
+	if eventRecorder == nil {
+		return
+	}
+
+	// Warnings during a passing spec may hide a latent issue
+	passed := map[string]bool{}
+	for _, spec := range report.SpecReports {
+		if spec.State == types.SpecStatePassed {
+			passed[spec.FullText()] = true
+		}
+	}
+	warnings := events.Filter(eventRecorder.Events(), func(e events.Event) bool {
+		return e.Type == "Warning" && passed[e.Spec]
+	})
+	if len(warnings) == 0 {
+		return
+	}
+
+	summary := events.Summary(warnings)
+	GinkgoWriter.Printf("Warning events during passing specs:\n%s", summary)
+
+	err := os.WriteFile(filepath.Join(stepsReportDir, "events-"+reportName()+"-warnings.txt"), []byte(summary), 0644)
+	Expect(err).To(Not(HaveOccurred()))
+})
@@ -36,7 +36,7 @@ var _ = Describe("E2E - Bootstrap node for UI", Label("ui"), func() {
 		wg                sync.WaitGroup
 	)
 
-	It("Configure libvirt and bootstrap a node", func() {
+	It("Configure libvirt and bootstrap a node", Label("qase-9", "qase-75"), func() {
 		By("Downloading MachineRegistration", func() {
 			tokenURL, err := kubectl.RunWithoutErr("get", "MachineRegistration",
 				"--namespace", clusterNS,
@@ -139,7 +139,7 @@ var _ = Describe("E2E - Bootstrap node for UI", Label("ui"), func() {
 		wg.Wait()
 	})
 
-	It("Add the nodes in Rancher Manager", func() {
+	It("Add the nodes in Rancher Manager", Label("qase-none"), func() {
 		// Wait a bit to make sure the VMs is really halted
 		// TODO: Find a better way to check this
 		time.Sleep(5 * time.Minute)
@@ -53,10 +53,7 @@ var _ = Describe("E2E - Uninstall Elemental Operator", Label("uninstall-operator
 		PollInterval: 500 * time.Millisecond,
 	}
 
-	It("Uninstall Elemental Operator", func() {
-		// Report to Qase
-		testCaseID = 70
-
+	It("Uninstall Elemental Operator", Label("qase-70"), func() {
 		By("Testing cluster resource availability BEFORE operator uninstallation", func() {
 			testClusterAvailability(clusterNS, clusterName)
 		})
@@ -179,10 +176,7 @@ var _ = Describe("E2E - Uninstall Elemental Operator", Label("uninstall-operator
 		})
 	})
 
-	It("Re-install Elemental Operator", func() {
-		// Report to Qase
-		testCaseID = 62
-
+	It("Re-install Elemental Operator", Label("qase-62"), func() {
 		By("Installing Operator via Helm", func() {
 			for _, chart := range []string{"elemental-operator-crds", "elemental-operator"} {
 				// Set flags for installation
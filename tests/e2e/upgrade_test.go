@@ -15,9 +15,10 @@ limitations under the License.
 package e2e_test
 
 import (
-	"maps"
 	"os"
 	"os/exec"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,7 +29,12 @@ import (
 	"github.com/rancher-sandbox/ele-testhelpers/kubectl"
 	"github.com/rancher-sandbox/ele-testhelpers/rancher"
 	"github.com/rancher-sandbox/ele-testhelpers/tools"
+	"github.com/rancher/elemental/tests/e2e/helpers/bootmode"
 	"github.com/rancher/elemental/tests/e2e/helpers/elemental"
+	"github.com/rancher/elemental/tests/e2e/helpers/misc"
+	"github.com/rancher/elemental/tests/e2e/helpers/persistence"
+	"github.com/rancher/elemental/tests/e2e/helpers/snapshot"
+	"github.com/rancher/elemental/tests/e2e/helpers/upgrade"
 	"gopkg.in/yaml.v3"
 )
 
@@ -52,6 +58,52 @@ func getAnnotations(cl *tools.Client) map[string]string {
 	return annotations
 }
 
+/*
+Get the MachineInventory annotations reporting the OS image or version that an upgrade changes
+  - @param annotations Annotations before the upgrade
+  - @param before os-release of the node before the upgrade
+  - @param after os-release of the upgrade image
+  - @returns The annotation keys, sorted
+*/
+func getOSAnnotations(annotations map[string]string, before, after upgrade.OSRelease) []string {
+	var keys []string
+	for k, v := range annotations {
+		for _, field := range []string{"IMAGE", "IMAGE_TAG", "IMAGE_REPO"} {
+			if before[field] != "" && before[field] != after[field] && v == before[field] {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+/*
+Get the annotations that should not be changed by an upgrade
+  - @param annotations Annotations of the MachineInventory before the upgrade
+  - @param osKeys Annotations reporting the OS image or version
+  - @returns All the other keys, sorted
+*/
+func getStableAnnotations(annotations map[string]string, osKeys []string) []string {
+	var keys []string
+	for k := range annotations {
+		if !slices.Contains(osKeys, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func getOSRelease(cl *tools.Client) upgrade.OSRelease {
+	out := RunSSHWithRetry(cl, "cat /etc/os-release")
+
+	return upgrade.ParseOSRelease(out)
+}
+
 var _ = Describe("E2E - Upgrading Elemental Operator", Label("upgrade-operator"), func() {
 	// Create kubectl context
 	// Default timeout is too small, so New() cannot be used
@@ -61,10 +113,7 @@ var _ = Describe("E2E - Upgrading Elemental Operator", Label("upgrade-operator")
 		PollInterval: 500 * time.Millisecond,
 	}
 
-	It("Upgrade operator", func() {
-		// Report to Qase
-		testCaseID = 71
-
+	It("Upgrade operator", Label("qase-71"), func() {
 		// Check if CRDs chart is already installed (not always the case in older versions)
 		chartList, err := exec.Command("helm",
 			"list",
@@ -91,10 +140,7 @@ var _ = Describe("E2E - Upgrading Rancher Manager", Label("upgrade-rancher-manag
 		PollInterval: 500 * time.Millisecond,
 	}
 
-	It("Upgrade Rancher Manager", func() {
-		// Report to Qase
-		testCaseID = 72
-
+	It("Upgrade Rancher Manager", Label("qase-72"), func() {
 		// Get before-upgrade Rancher Manager version
 		getImageVersion := []string{
 			"get", "pod",
@@ -105,6 +151,9 @@ var _ = Describe("E2E - Upgrading Rancher Manager", Label("upgrade-rancher-manag
 		versionBeforeUpgrade, err := kubectl.RunWithoutErr(getImageVersion...)
 		Expect(err).To(Not(HaveOccurred()))
 
+		// Elemental resources should not be modified by the upgrade
+		fingerprintBefore := CaptureFingerprint("before-rancher-upgrade")
+
 		// Upgrade Rancher Manager
 		// NOTE: Don't check the status, we can have false-positive here...
 		//       Better to check the rollout after the upgrade, it will fail if the upgrade failed
@@ -149,22 +198,28 @@ var _ = Describe("E2E - Upgrading Rancher Manager", Label("upgrade-rancher-manag
 		versionAfterUpgrade, err := kubectl.RunWithoutErr(getImageVersion...)
 		Expect(err).To(Not(HaveOccurred()))
 		Expect(versionAfterUpgrade).To(Not(Equal(versionBeforeUpgrade)))
+
+		// Check Elemental resources after the upgrade
+		CheckFingerprint(fingerprintBefore)
 	})
 })
 
 var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 	var (
-		annotationsAfter  map[string]string
-		annotationsBefore map[string]string
+		annotationsBefore misc.NodeStore[map[string]string]
+		checkers          misc.NodeStore[*persistence.Checker]
+		imageToUse        string
+		lockTimesBefore   misc.NodeStore[int64]
+		recoveriesBefore  misc.NodeStore[upgrade.OSRelease]
+		recoveryMutex     sync.Mutex
+		releasesBefore    misc.NodeStore[upgrade.OSRelease]
+		snapshotsBefore   misc.NodeStore[[]snapshot.Snapshot]
 		value             string
 		valueToCheck      string
 		wg                sync.WaitGroup
 	)
 
-	It("Upgrade node", func() {
-		// Report to Qase
-		testCaseID = 73
-
+	It("Upgrade node", Label("qase-73"), func() {
 		By("Checking if upgrade type is set", func() {
 			Expect(upgradeType).To(Not(BeEmpty()))
 		})
@@ -185,7 +240,41 @@ var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 				defer GinkgoRecover()
 
 				By("Getting annotations for "+h+" before upgrade", func() {
-					annotationsBefore = getAnnotations(cl)
+					annotationsBefore.Set(h, getAnnotations(cl))
+				})
+
+				By("Getting OS release for "+h+" before upgrade", func() {
+					releasesBefore.Set(h, getOSRelease(cl))
+				})
+
+				By("Getting snapshots for "+h+" before upgrade", func() {
+					CheckSnapshotter(cl)
+					snapshotsBefore.Set(h, GetSnapshots(cl))
+				})
+
+				By("Getting last upgrade job time for "+h+" before upgrade", func() {
+					lockTime, err := upgrade.LockTime(cl)
+					Expect(err).To(Not(HaveOccurred()))
+					lockTimesBefore.Set(h, lockTime)
+				})
+
+				if upgradeRecovery || upgradeRecoveryOnly {
+					By("Getting recovery OS release for "+h+" before upgrade", func() {
+						// Only one node at a time is rebooted, to keep the cluster alive
+						recoveryMutex.Lock()
+						defer recoveryMutex.Unlock()
+
+						BootNodeInto(cl, bootmode.Recovery)
+						recoveriesBefore.Set(h, getOSRelease(cl))
+						RestoreBootMode(cl)
+					})
+				}
+
+				// Must be done after any reboot, to keep ephemeral markers
+				By("Writing persistence markers on "+h+" before upgrade", func() {
+					checker := persistence.New(strconv.FormatInt(time.Now().UnixNano(), 10))
+					Expect(checker.Write(cl)).To(Succeed())
+					checkers.Set(h, checker)
 				})
 			}(hostName, client)
 		}
@@ -201,63 +290,40 @@ var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 
 			if upgradeType == "managedOSVersionName" {
 				// Get OSVersion name
-				OSVersion, err := exec.Command(getOSScript, upgradeOSChannel).Output()
-				Expect(err).To(Not(HaveOccurred()))
+				OSVersion := GetOSVersionName(upgradeOSChannel, elemental.OSVersionContainer)
 
 				// In case of sync failure OSVersion can be empty,
 				// so try to force the sync before aborting
-				if string(OSVersion) == "" {
+				if OSVersion == "" {
 					const channel = "unstable-testing-channel"
 
 					// Log the workaround, could be useful
 					GinkgoWriter.Printf("!! ManagedOSVersionChannel not synced !! Triggering a re-sync!\n")
 
-					// Get current syncInterval
-					syncValue, err := kubectl.RunWithoutErr("get", "managedOSVersionChannel",
-						"--namespace", clusterNS, channel,
-						"-o", "jsonpath={.spec.syncInterval}")
-					Expect(err).To(Not(HaveOccurred()))
-					Expect(syncValue).To(Not(BeEmpty()))
-
-					// Reduce syncInterval to force an update
-					_, err = kubectl.RunWithoutErr("patch", "managedOSVersionChannel",
-						"--namespace", clusterNS, channel,
-						"--type", "merge",
-						"--patch", "{\"spec\":{\"syncInterval\":\"1m\"}}")
+					err := elemental.SyncOSVersionChannel(clusterNS, channel, tools.SetTimeout(4*time.Minute))
 					Expect(err).To(Not(HaveOccurred()))
 
-					// Loop until sync is done
-					Eventually(func() string {
-						value, _ := exec.Command(getOSScript, upgradeOSChannel).Output()
-
-						return string(value)
-					}, tools.SetTimeout(4*time.Minute), 30*time.Second).Should(Not(BeEmpty()))
-
 					// We should now have an OS version!
-					OSVersion, err = exec.Command(getOSScript, upgradeOSChannel).Output()
-					Expect(err).To(Not(HaveOccurred()))
-					Expect(OSVersion).To(Not(BeEmpty()))
-
-					// Re-patch syncInterval to the initial value
-					_, err = kubectl.RunWithoutErr("patch", "managedOSVersionChannel",
-						"--namespace", clusterNS, channel,
-						"--type", "merge",
-						"--patch", "{\"spec\":{\"syncInterval\":\""+syncValue+"\"}}")
-					Expect(err).To(Not(HaveOccurred()))
+					Eventually(func() string {
+						OSVersion = GetOSVersionName(upgradeOSChannel, elemental.OSVersionContainer)
+						return OSVersion
+					}, tools.SetTimeout(2*time.Minute), 10*time.Second).Should(Not(BeEmpty()))
 				}
 
 				// Set OS image to use for upgrade
-				value = string(OSVersion)
+				value = OSVersion
 
 				// Extract the value to check after the upgrade
 				out, err := kubectl.RunWithoutErr("get", "ManagedOSVersion",
 					"--namespace", clusterNS, value,
 					"-o", "jsonpath={.spec.metadata.upgradeImage}")
 				Expect(err).To(Not(HaveOccurred()))
+				imageToUse = out
 				valueToCheck = tools.TrimStringFromChar(out, ":")
 			} else if upgradeType == "osImage" {
 				// Set OS image to use for upgrade
 				value = upgradeImage
+				imageToUse = upgradeImage
 
 				// Extract the value to check after the upgrade
 				valueToCheck = tools.TrimStringFromChar(upgradeImage, ":")
@@ -308,6 +374,14 @@ var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 					key:   "%FORCE_DOWNGRADE%",
 					value: strconv.FormatBool(forceDowngrade),
 				},
+				{
+					key:   "%UPGRADE_RECOVERY_ONLY%",
+					value: strconv.FormatBool(upgradeRecoveryOnly),
+				},
+				{
+					key:   "%UPGRADE_RECOVERY%",
+					value: strconv.FormatBool(upgradeRecovery),
+				},
 			}
 
 			// Create Yaml file
@@ -330,11 +404,12 @@ var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 			client, _ := GetNodeInfo(hostName)
 			Expect(client).To(Not(BeNil()))
 
-			// Toggle Grub recovery entry test if only one node is upgraded
-			grubRecovery := false
-			if usedNodes == 1 {
-				grubRecovery = true
-			}
+			// Predict what suc-upgrade will do on this node
+			releaseBefore, _ := releasesBefore.Get(hostName)
+			snapshotBefore, _ := snapshotsBefore.Get(hostName)
+			decision := upgrade.Decide(releaseBefore, upgrade.NewOSReleaseFromImage(imageToUse), forceDowngrade, upgradeRecoveryOnly)
+			GinkgoWriter.Printf("Expected upgrade decision on %s from %s to %s: %s\n",
+				hostName, releaseBefore["IMAGE"], imageToUse, decision)
 
 			// Execute node deployment in parallel
 			wg.Add(1)
@@ -342,60 +417,157 @@ var _ = Describe("E2E - Upgrading node", Label("upgrade-node"), func() {
 				defer wg.Done()
 				defer GinkgoRecover()
 
-				By("Checking VM upgrade on "+h, func() {
-					Eventually(func() string {
-						// Use grep here in case of comment in the file!
-						out, _ := cl.RunSSH("eval $(grep -v ^# /etc/os-release) && echo ${IMAGE}")
+				// Use grep here in case of comment in the file!
+				getImage := func() string {
+					out, _ := cl.RunSSH("eval $(grep -v ^# /etc/os-release) && echo ${IMAGE}")
+					return strings.Trim(out, "\n")
+				}
 
-						// This remove the version and keep only the repo, as in the file
-						// we have the exact version and we don't know it before the upgrade
-						return tools.TrimStringFromChar(strings.Trim(out, "\n"), ":")
-					}, tools.SetTimeout(10*time.Minute), 30*time.Second).Should(Equal(valueToCheck))
-				})
+				if decision != upgrade.Upgrade {
+					By("Checking that VM upgrade is refused on "+h, func() {
+						Consistently(getImage, tools.SetTimeout(5*time.Minute), 30*time.Second).Should(Equal(releaseBefore["IMAGE"]))
+					})
 
-				By("Getting annotations for "+h+" after upgrade", func() {
-					annotationsAfter = getAnnotations(cl)
-				})
+					By("Checking that snapshots have not been changed on "+h, func() {
+						Expect(GetSnapshots(cl)).To(Equal(snapshotBefore))
+					})
 
-				By("Checking that annotations have been updated after upgrade", func() {
-					// Maps should not be equal after an upgrade
-					status := maps.Equal(annotationsBefore, annotationsAfter)
-					if status {
-						GinkgoWriter.Println("Annotations have not been updated!")
-					}
-					Expect(status).To(BeFalse())
-				})
+					By("Removing persistence markers on "+h, func() {
+						checker, found := checkers.Get(h)
+						Expect(found).To(BeTrue())
+						Expect(checker.Cleanup(cl)).To(Succeed())
+					})
 
-				if grubRecovery {
-					By("Testing Grub Recovery entry on "+h+" after upgrade", func() {
-						_ = RunSSHWithRetry(cl, "grub2-editenv /oem/grubenv set next_entry=recovery")
+					// Nothing more to check as the node has not been upgraded
+					return
+				}
 
-						// Check that the recovery entry is selected
-						out := RunSSHWithRetry(cl, "grub2-editenv /oem/grubenv list | grep next_entry")
-						Expect(out).To(ContainSubstring("recovery"))
+				if upgradeRecoveryOnly {
+					By("Waiting for recovery upgrade on "+h, func() {
+						lockTimeBefore, _ := lockTimesBefore.Get(h)
+
+						// suc-upgrade does not reboot the node when only recovery is upgraded
+						Eventually(func() bool {
+							lockTime, err := upgrade.LockTime(cl)
+							if err != nil || lockTime <= lockTimeBefore {
+								return false
+							}
+							running, err := upgrade.IsRunning(cl)
+							return err == nil && !running
+						}, tools.SetTimeout(10*time.Minute), 30*time.Second).Should(BeTrue())
+					})
 
-						// Reboot in recovery, execute 'reboot' in background, to avoid SSH locking
-						_ = RunSSHWithRetry(cl, "setsid -f reboot")
+					By("Checking that active system has not been changed on "+h, func() {
+						Expect(getImage()).To(Equal(releaseBefore["IMAGE"]))
+						Expect(GetSnapshots(cl)).To(Equal(snapshotBefore))
+					})
+				} else {
+					By("Checking VM upgrade on "+h, func() {
+						Eventually(func() string {
+							// This remove the version and keep only the repo, as in the file
+							// we have the exact version and we don't know it before the upgrade
+							return tools.TrimStringFromChar(getImage(), ":")
+						}, tools.SetTimeout(10*time.Minute), 30*time.Second).Should(Equal(valueToCheck))
+					})
 
-						// Check the mode after reboot
-						out = RunSSHWithRetry(cl, "cat /run/elemental/recovery_mode")
-						Expect(out).To(Not(BeEmpty()))
+					By("Checking snapshots on "+h+" after upgrade", func() {
+						CheckUpgradeSnapshots(snapshotBefore, GetSnapshots(cl))
+					})
 
-						// Reboot in active (normal) mode
-						_ = RunSSHWithRetry(cl, "grub2-editenv /oem/grubenv set next_entry=active")
+					By("Checking that annotations have been updated on "+h+" after upgrade", func() {
+						before, found := annotationsBefore.Get(h)
+						Expect(found).To(BeTrue())
 
-						// Check that the active entry is selected
-						out = RunSSHWithRetry(cl, "grub2-editenv /oem/grubenv list | grep next_entry")
-						Expect(out).To(ContainSubstring("active"))
+						diff := misc.DiffMaps(before, getAnnotations(cl))
+						GinkgoWriter.Printf("Annotations diff for %s:\n%s\n", h, diff)
 
-						// Final reboot in active mode
-						_ = RunSSHWithRetry(cl, "setsid -f reboot")
+						// Annotations reporting the OS image and version must be updated after an upgrade
+						releaseBefore, _ := releasesBefore.Get(h)
+						mustChange := getOSAnnotations(before, releaseBefore, upgrade.NewOSReleaseFromImage(imageToUse))
+						Expect(mustChange).To(Not(BeEmpty()), "No annotation reports the OS image or version!")
+
+						// Other annotations (registration, hardware, ...) must be kept as-is
+						Expect(diff.Check(misc.DiffExpectation{
+							MustChange: mustChange,
+							MustStay:   getStableAnnotations(before, mustChange),
+						})).To(Succeed())
+					})
 
-						// Check the mode after final reboot
-						out = RunSSHWithRetry(cl, "cat /run/elemental/active_mode")
-						Expect(out).To(Not(BeEmpty()))
+					By("Checking persistence markers on "+h+" after upgrade", func() {
+						checker, found := checkers.Get(h)
+						Expect(found).To(BeTrue())
+						Expect(checker.Verify(cl, persistence.Upgrade)).To(Succeed())
 					})
 				}
+
+				if !upgradeRecoveryOnly {
+					By("Waiting for the upgrade job to be done on "+h, func() {
+						// Passive snapshot is seen as not upgraded, so SUC could upgrade
+						// and reboot the node again if its job is not marked as done
+						Eventually(func() bool {
+							planName, planHash := getUpgradePlan(cl, "with-"+strings.ToLower(upgradeType))
+							return planHash != "" && getAppliedPlanHash(cl, planName) == planHash
+						}, tools.SetTimeout(10*time.Minute), 30*time.Second).Should(BeTrue())
+					})
+
+					By("Testing Grub Passive entry on "+h+" after upgrade", func() {
+						// Only one node at a time is rebooted, to keep the cluster alive
+						recoveryMutex.Lock()
+						defer recoveryMutex.Unlock()
+
+						// Passive snapshot should be the system before the upgrade
+						BootNodeInto(cl, bootmode.Passive)
+						Expect(getOSRelease(cl)["IMAGE"]).To(Equal(releaseBefore["IMAGE"]))
+
+						// Reboot in active (normal) mode
+						RestoreBootMode(cl)
+						Expect(tools.TrimStringFromChar(getImage(), ":")).To(Equal(valueToCheck))
+					})
+				}
+
+				By("Testing Grub Recovery entry on "+h+" after upgrade", func() {
+					// Recovery should not alter snapshots
+					activeBefore, err := snapshot.Active(GetSnapshots(cl))
+					Expect(err).To(Not(HaveOccurred()))
+
+					// Only one node at a time is rebooted, to keep the cluster alive
+					recoveryMutex.Lock()
+					defer recoveryMutex.Unlock()
+
+					// Reboot in recovery
+					BootNodeInto(cl, bootmode.Recovery)
+
+					// Recovery os-release should be readable, log it for debugging purposes
+					recoveryRelease := getOSRelease(cl)
+					Expect(recoveryRelease["IMAGE"]).To(Not(BeEmpty()))
+					GinkgoWriter.Printf("Recovery image on %s: %s\n", h, recoveryRelease["IMAGE"])
+
+					if upgradeRecovery || upgradeRecoveryOnly {
+						recoveryBefore, found := recoveriesBefore.Get(h)
+						Expect(found).To(BeTrue())
+
+						// Only the repo is checked, as for the active system
+						Expect(tools.TrimStringFromChar(recoveryRelease["IMAGE"], ":")).To(Equal(valueToCheck))
+						if recoveryBefore["IMAGE"] != imageToUse {
+							Expect(recoveryRelease["IMAGE"]).To(Not(Equal(recoveryBefore["IMAGE"])))
+						}
+					}
+
+					// Reboot in active (normal) mode
+					RestoreBootMode(cl)
+
+					// Only persistent markers should survive a reboot, they are not needed anymore
+					checker, found := checkers.Get(h)
+					Expect(found).To(BeTrue())
+					err = checker.Verify(cl, persistence.Reboot)
+					Expect(checker.Cleanup(cl)).To(Succeed())
+					Expect(err).To(Not(HaveOccurred()))
+
+					// Check that the same snapshot is still used
+					activeAfter, err := snapshot.Active(GetSnapshots(cl))
+					Expect(err).To(Not(HaveOccurred()))
+					Expect(activeAfter).To(Equal(activeBefore))
+				})
 			}(hostName, client)
 		}
 
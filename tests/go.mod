@@ -12,6 +12,7 @@ require (
 	github.com/rancher-sandbox/ele-testhelpers v0.0.0-20241112093046-812bbbbdb8e3
 	github.com/rancher-sandbox/qase-ginkgo v1.0.1
 	github.com/sirupsen/logrus v1.9.3
+	go.qase.io/client v0.0.0-20231114201952-65195ec001fa
 	golang.org/x/mod v0.21.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -24,7 +25,6 @@ require (
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/google/pprof v0.0.0-20240925223930-fa3061bff0bc // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	go.qase.io/client v0.0.0-20231114201952-65195ec001fa // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/crypto v0.27.0 // indirect
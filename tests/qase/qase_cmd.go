@@ -15,12 +15,16 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	qase "github.com/rancher-sandbox/qase-ginkgo"
+	"github.com/rancher/elemental/tests/e2e/helpers/qaseapi"
+	"github.com/rancher/elemental/tests/e2e/helpers/specs"
 	"github.com/sirupsen/logrus"
 )
 
@@ -39,11 +43,233 @@ func init() {
 	}
 }
 
+// Result of the synchronisation of a case
+type syncedCase struct {
+	CaseID int64    `json:"caseId"`
+	Title  string   `json:"title"`
+	Tags   []string `json:"tags"`
+	Status string   `json:"status"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+/*
+Print a value as JSON on stdout
+  - @param v Value to print
+  - @returns Fatal on error
+*/
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logrus.Fatalf("Error on encoding output: %v", err)
+	}
+}
+
+/*
+List the runs of the project
+  - @param args Arguments of the subcommand
+  - @returns Fatal on error
+*/
+func listRuns(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	status := fs.String("status", "", "only list runs with this status (active, complete, abort)")
+	_ = fs.Parse(args)
+
+	runs, err := qaseapi.New().ListRuns(*status)
+	if err != nil {
+		logrus.Fatalf("Error on listing runs: %v", err)
+	}
+	printJSON(runs)
+}
+
+/*
+Show a run with its results
+  - @param args Arguments of the subcommand
+  - @returns Fatal on error
+*/
+func showRun(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	id := fs.Int64("run", int64(runID), "ID of the run, QASE_RUN_ID by default")
+	_ = fs.Parse(args)
+
+	client := qaseapi.New()
+	run, err := client.GetRun(*id)
+	if err != nil {
+		logrus.Fatalf("Error on getting run %d: %v", *id, err)
+	}
+	results, err := client.ListResults(*id)
+	if err != nil {
+		logrus.Fatalf("Error on getting results of run %d: %v", *id, err)
+	}
+
+	printJSON(struct {
+		Run     qaseapi.Run      `json:"run"`
+		Results []qaseapi.Result `json:"results"`
+	}{run, results})
+}
+
+/*
+Upload files and attach them to a result or to all the results of a run
+  - @param args Arguments of the subcommand
+  - @returns Fatal on error
+*/
+func attachFiles(args []string) {
+	fs := flag.NewFlagSet("attach", flag.ExitOnError)
+	id := fs.Int64("run", int64(runID), "ID of the run, QASE_RUN_ID by default, files are only uploaded if not set")
+	hash := fs.String("result", "", "hash of the result, all the results of the run by default")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		logrus.Fatalln("No file to attach")
+	}
+
+	client := qaseapi.New()
+
+	var (
+		attachments []qaseapi.Attachment
+		hashes      []string
+	)
+	for _, file := range fs.Args() {
+		a, err := client.Upload(file)
+		if err != nil {
+			logrus.Fatalf("Error on uploading %s: %v", file, err)
+		}
+		logrus.Debugf("File %s uploaded with hash %s", file, a.Hash)
+		attachments = append(attachments, a)
+		hashes = append(hashes, a.Hash)
+	}
+
+	// Qase runs have no attachments, files are linked to the results
+	var results []string
+	if *id > 0 {
+		if *hash != "" {
+			results = []string{*hash}
+		} else {
+			list, err := client.ListResults(*id)
+			if err != nil {
+				logrus.Fatalf("Error on getting results of run %d: %v", *id, err)
+			}
+			for _, r := range list {
+				results = append(results, r.Hash)
+			}
+		}
+
+		for _, r := range results {
+			if err := client.AttachToResult(*id, r, hashes); err != nil {
+				logrus.Fatalf("Error on attaching files to result %s: %v", r, err)
+			}
+		}
+	}
+
+	printJSON(struct {
+		Run         int64                `json:"run,omitempty"`
+		Results     []string             `json:"results"`
+		Attachments []qaseapi.Attachment `json:"attachments"`
+	}{*id, results, attachments})
+}
+
+/*
+Push the title and the labels of the specs to their Qase case
+  - @param args Arguments of the subcommand
+  - @returns Fatal on error
+*/
+func syncCases(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("dir", "e2e", "directory of the specs")
+	dryRun := fs.Bool("dry-run", false, "only show what would be updated")
+	_ = fs.Parse(args)
+
+	all, err := specs.Scan(*dir)
+	if err != nil {
+		logrus.Fatalf("Error on scanning specs: %v", err)
+	}
+
+	client := qaseapi.New()
+	registry := specs.ByCaseID(all)
+
+	var synced []syncedCase
+	for _, id := range specs.SortedIDs(registry) {
+		s := registry[id][0]
+
+		c := syncedCase{CaseID: id, Title: s.Title(), Tags: s.Tags()}
+		switch {
+		case len(registry[id]) > 1:
+			// Not possible to know which spec is the right one
+			c.Status = "skipped"
+			c.Reason = fmt.Sprintf("case id used by %d specs", len(registry[id]))
+		case *dryRun:
+			c.Status = "dry-run"
+		default:
+			c.Status = "updated"
+			if err := client.UpdateCase(c.CaseID, c.Title, c.Tags); err != nil {
+				c.Status = "failed"
+				c.Reason = err.Error()
+			}
+		}
+		synced = append(synced, c)
+	}
+
+	printJSON(synced)
+}
+
+/*
+Show the specs of each Qase case
+  - @param args Arguments of the subcommand
+  - @returns Fatal on error
+*/
+func listCases(args []string) {
+	fs := flag.NewFlagSet("cases", flag.ExitOnError)
+	dir := fs.String("dir", "e2e", "directory of the specs")
+	markdown := fs.Bool("markdown", false, "output a Markdown table instead of JSON")
+	_ = fs.Parse(args)
+
+	all, err := specs.Scan(*dir)
+	if err != nil {
+		logrus.Fatalf("Error on scanning specs: %v", err)
+	}
+	registry := specs.ByCaseID(all)
+
+	if *markdown {
+		fmt.Println("| Qase ID | File | Spec |")
+		fmt.Println("| ------- | ---- | ---- |")
+		for _, id := range specs.SortedIDs(registry) {
+			for _, s := range registry[id] {
+				fmt.Printf("| %d | `%s` | %s |\n", id, s.File, s.Title())
+			}
+		}
+		return
+	}
+
+	type caseSpecs struct {
+		CaseID int64        `json:"caseId"`
+		Specs  []specs.Spec `json:"specs"`
+	}
+
+	var cases []caseSpecs
+	for _, id := range specs.SortedIDs(registry) {
+		cases = append(cases, caseSpecs{id, registry[id]})
+	}
+	printJSON(cases)
+}
+
 func main() {
 	// Define the allowed options
 	createRun := flag.Bool("create", false, "create a new Qase run")
 	deleteRun := flag.Bool("delete", false, "delete a Qase run, QASE_RUN_ID should be set")
 	publishRun := flag.Bool("publish", false, "publish a Qase report, QASE_RUN_ID should be set, it also depends on QASE_REPORT and QASE_RUN_COMPLETE")
+	uploadSpool := flag.Bool("upload-spool", false, "upload the results spooled during an offline run, QASE_RUN_ID is used for results without run")
+	spoolDir := flag.String("spool-dir", os.Getenv("QASE_SPOOL_DIR"), "spool directory, QASE_SPOOL_DIR by default")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-create|-delete|-publish|-upload-spool] | <list|show|attach|sync|cases> [options]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), `Subcommands, output is in JSON format:
+  list    list the runs of the project
+  show    show a run and its results
+  attach  upload files and attach them to the results of a run
+  sync    push the title and the labels of the specs to their Qase case
+  cases   show the specs of each Qase case`)
+	}
 
 	// Parse the arguments
 	flag.Parse()
@@ -54,14 +280,40 @@ func main() {
 		if id <= 0 {
 			logrus.Fatalln("Error on creating Qase run")
 		}
-		logrus.Debugf("Qase run id %d created", id)
+		logrus.Infof("Qase run id %d created", id)
 		fmt.Printf("%d", id)
 	} else if *deleteRun {
 		qase.DeleteRun()
-		logrus.Debugf("Qase run id %d deleted", runID)
+		logrus.Infof("Qase run id %d deleted", runID)
+	} else if *uploadSpool {
+		if *spoolDir == "" {
+			logrus.Fatalln("No spool directory, -spool-dir or QASE_SPOOL_DIR should be set")
+		}
+		report, err := qaseapi.New().UploadSpool(*spoolDir, int64(runID), 3, 5*time.Second)
+		printJSON(report)
+		if err != nil {
+			logrus.Fatalf("Error on uploading spooled results: %v", err)
+		}
+		logrus.Infof("%d spooled results uploaded", len(report.Uploaded))
 	} else if *publishRun {
 		qase.FinalizeResults()
-		logrus.Debugf("Qase finalization for run id %d has been done", runID)
+		logrus.Infof("Qase finalization for run id %d has been done", runID)
+	} else if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "list":
+			listRuns(flag.Args()[1:])
+		case "show":
+			showRun(flag.Args()[1:])
+		case "attach":
+			attachFiles(flag.Args()[1:])
+		case "sync":
+			syncCases(flag.Args()[1:])
+		case "cases":
+			listCases(flag.Args()[1:])
+		default:
+			flag.Usage()
+			os.Exit(2)
+		}
 	} else {
 		logrus.Debugln("Nothing to do!")
 	}